@@ -0,0 +1,144 @@
+package assertion
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type jsonReport struct {
+	Passed  int          `json:"passed"`
+	Failed  int          `json:"failed"`
+	Results []jsonResult `json:"results"`
+}
+
+type jsonResult struct {
+	Table       string           `json:"table"`
+	Column      string           `json:"column,omitempty"`
+	Kind        string           `json:"kind"`
+	Name        string           `json:"name"`
+	Query       string           `json:"query"`
+	Passed      bool             `json:"passed"`
+	FailingRows int64            `json:"failing_rows"`
+	Samples     []map[string]any `json:"samples,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	DurationMs  int64            `json:"duration_ms"`
+}
+
+// WriteJSON writes the results as JSON report.
+func WriteJSON(w io.Writer, results []Result) error {
+	rep := jsonReport{Failed: Failed(results), Results: []jsonResult{}}
+	rep.Passed = len(results) - rep.Failed
+	for _, r := range results {
+		rep.Results = append(rep.Results, jsonResult{
+			Table:       r.Table,
+			Column:      r.Column,
+			Kind:        r.Kind,
+			Name:        r.Name,
+			Query:       r.Query,
+			Passed:      r.Passed,
+			FailingRows: r.FailingRows,
+			Samples:     r.Samples,
+			Error:       r.Error,
+			DurationMs:  r.Duration.Milliseconds(),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the results as JUnit XML report, with one test suite
+// per table. The failing sample rows are part of the failure text.
+func WriteJUnit(w io.Writer, results []Result) error {
+	var suites junitSuites
+	idx := map[string]int{}
+	var durations []time.Duration
+	for _, r := range results {
+		i, ok := idx[r.Table]
+		if !ok {
+			i = len(suites.Suites)
+			idx[r.Table] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: r.Table})
+			durations = append(durations, 0)
+		}
+		s := &suites.Suites[i]
+		durations[i] += r.Duration
+		tc := junitCase{Classname: r.Table, Name: r.Name, Time: seconds(r.Duration)}
+		switch {
+		case r.Error != "":
+			s.Errors++
+			tc.Error = &junitMessage{Message: r.Error, Type: r.Kind, Body: r.Query}
+		case !r.Passed:
+			s.Failures++
+			tc.Failure = &junitMessage{
+				Message: fmt.Sprintf("%d failing rows", r.FailingRows),
+				Type:    r.Kind,
+				Body:    failureBody(r),
+			}
+		}
+		s.Tests++
+		s.Cases = append(s.Cases, tc)
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = seconds(durations[i])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func failureBody(r Result) string {
+	var sb strings.Builder
+	sb.WriteString(r.Query)
+	sb.WriteString("\n\nsample rows:\n")
+	for _, s := range r.Samples {
+		b, _ := json.Marshal(s)
+		sb.Write(b)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
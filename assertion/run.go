@@ -0,0 +1,94 @@
+package assertion
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+)
+
+// Result is the outcome of a check. A check that could not be executed has
+// an Error and is not Passed.
+type Result struct {
+	Check
+	Passed      bool
+	FailingRows int64
+	// Samples contains up to RunOptions.Samples failing rows.
+	Samples  []map[string]any
+	Error    string
+	Duration time.Duration
+}
+
+type RunOptions struct {
+	// Samples is the maximum number of failing rows kept per check.
+	Samples int
+}
+
+// Run executes the checks. Errors of single checks are recorded in their
+// result; only a cancelled ctx stops the run.
+func Run(ctx context.Context, db *sql.DB, checks []Check, opts RunOptions) ([]Result, error) {
+	res := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		start := time.Now()
+		r := Result{Check: c}
+		if err := runCheck(ctx, db, &r, opts); err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			r.Error = err.Error()
+		}
+		r.Passed = r.Error == "" && r.FailingRows == 0
+		r.Duration = time.Since(start)
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func runCheck(ctx context.Context, db *sql.DB, r *Result, opts RunOptions) error {
+	rows, err := db.QueryContext(ctx, r.Query, r.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if r.Regex != nil && r.Regex.MatchString(mssqlload.FormatValue(vals[0], "")) {
+			continue
+		}
+		r.FailingRows++
+		if len(r.Samples) < opts.Samples {
+			sample := make(map[string]any, len(cols))
+			for i, v := range vals {
+				if b, ok := v.([]byte); ok {
+					v = string(b)
+				}
+				sample[cols[i]] = v
+			}
+			r.Samples = append(r.Samples, sample)
+		}
+	}
+	return rows.Err()
+}
+
+// Failed returns the number of results that did not pass.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if !r.Passed {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,192 @@
+// Package assertion runs data quality checks, defined per table in a
+// YAML/JSON spec, against a database:
+//
+//	tables:
+//	  pokemon.pokemon:
+//	    min_rows: 1
+//	    columns:
+//	      name: {not_null: true, unique: true, regex: "^[A-Z]"}
+//	      hp: {min: 1, max: 255}
+//	      evolved_from: {references: pokemon.pokemon.name}
+//	    sql:
+//	      - name: legendary pokemon are strong
+//	        query: SELECT * FROM pokemon.pokemon WHERE legendary = 1 AND hp < 100
+//
+// Every check is turned into a query that returns the failing rows.
+package assertion
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"gopkg.in/yaml.v3"
+)
+
+type Spec struct {
+	Tables map[string]TableSpec `yaml:"tables"`
+}
+
+type TableSpec struct {
+	// MinRows is the minimum number of rows of the table.
+	MinRows *int64                `yaml:"min_rows"`
+	Columns map[string]ColumnSpec `yaml:"columns"`
+	SQL     []CustomCheck         `yaml:"sql"`
+}
+
+type ColumnSpec struct {
+	NotNull bool     `yaml:"not_null"`
+	Unique  bool     `yaml:"unique"`
+	Min     *float64 `yaml:"min"`
+	Max     *float64 `yaml:"max"`
+	// Regex is matched against the non-NULL values. SQL Server has no regex
+	// support, so the values are matched client side.
+	Regex string `yaml:"regex"`
+	// References is the referenced column as schema.table.column.
+	References string `yaml:"references"`
+}
+
+// CustomCheck is a query that has to return zero rows.
+type CustomCheck struct {
+	Name  string `yaml:"name"`
+	Query string `yaml:"query"`
+}
+
+// Check is a single assertion. Query returns the failing rows. If Regex is
+// set, Query returns the values to match instead and the failing rows are
+// determined client side.
+type Check struct {
+	Table  string
+	Column string
+	// Kind is not_null, unique, range, regex, references, min_rows or sql.
+	Kind  string
+	Name  string
+	Query string
+	Args  []any
+	Regex *regexp.Regexp
+}
+
+func LoadSpec(f string) (Spec, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return Spec{}, err
+	}
+	return ParseSpec(b)
+}
+
+// ParseSpec parses a YAML or JSON spec.
+func ParseSpec(b []byte) (Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return Spec{}, err
+	}
+	if len(spec.Tables) == 0 {
+		return Spec{}, fmt.Errorf("spec does not contain any tables")
+	}
+	return spec, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Checks generates the checks of the spec, ordered by table and column.
+func (s Spec) Checks() ([]Check, error) {
+	var checks []Check
+	for _, table := range sortedKeys(s.Tables) {
+		ts := s.Tables[table]
+		qtable := mssqlload.QuoteTableName(table)
+		if ts.MinRows != nil {
+			checks = append(checks, Check{
+				Table: table,
+				Kind:  "min_rows",
+				Name:  fmt.Sprintf("at least %d rows", *ts.MinRows),
+				Query: "SELECT COUNT_BIG(*) AS [count] FROM " + qtable + " HAVING COUNT_BIG(*) < @p1",
+				Args:  []any{*ts.MinRows},
+			})
+		}
+		for _, col := range sortedKeys(ts.Columns) {
+			cs := ts.Columns[col]
+			cc, err := columnChecks(table, col, cs)
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, cc...)
+		}
+		for i, c := range ts.SQL {
+			if strings.TrimSpace(c.Query) == "" {
+				return nil, fmt.Errorf("%s: sql check %d has no query", table, i+1)
+			}
+			name := c.Name
+			if name == "" {
+				name = fmt.Sprintf("sql %d", i+1)
+			}
+			checks = append(checks, Check{Table: table, Kind: "sql", Name: name, Query: c.Query})
+		}
+	}
+	return checks, nil
+}
+
+func columnChecks(table string, col string, cs ColumnSpec) ([]Check, error) {
+	qtable := mssqlload.QuoteTableName(table)
+	qcol := mssqlload.QuoteName(col)
+	var checks []Check
+	add := func(kind, name, query string, args ...any) {
+		checks = append(checks, Check{Table: table, Column: col, Kind: kind, Name: name, Query: query, Args: args})
+	}
+
+	if cs.NotNull {
+		add("not_null", col+" is not null",
+			fmt.Sprintf("SELECT * FROM %s WHERE %s IS NULL", qtable, qcol))
+	}
+	if cs.Unique {
+		add("unique", col+" is unique",
+			fmt.Sprintf("SELECT %[2]s, COUNT_BIG(*) AS [count] FROM %[1]s WHERE %[2]s IS NOT NULL GROUP BY %[2]s HAVING COUNT_BIG(*) > 1", qtable, qcol))
+	}
+	switch {
+	case cs.Min != nil && cs.Max != nil:
+		add("range", fmt.Sprintf("%s between %g and %g", col, *cs.Min, *cs.Max),
+			fmt.Sprintf("SELECT * FROM %s WHERE %[2]s < @p1 OR %[2]s > @p2", qtable, qcol), *cs.Min, *cs.Max)
+	case cs.Min != nil:
+		add("range", fmt.Sprintf("%s >= %g", col, *cs.Min),
+			fmt.Sprintf("SELECT * FROM %s WHERE %s < @p1", qtable, qcol), *cs.Min)
+	case cs.Max != nil:
+		add("range", fmt.Sprintf("%s <= %g", col, *cs.Max),
+			fmt.Sprintf("SELECT * FROM %s WHERE %s > @p1", qtable, qcol), *cs.Max)
+	}
+	if cs.Regex != "" {
+		re, err := regexp.Compile(cs.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: invalid regex: %w", table, col, err)
+		}
+		checks = append(checks, Check{
+			Table:  table,
+			Column: col,
+			Kind:   "regex",
+			Name:   fmt.Sprintf("%s matches %s", col, cs.Regex),
+			// every row, DISTINCT would fold values that only differ in case
+			// under a case insensitive collation
+			Query: fmt.Sprintf("SELECT %[2]s FROM %[1]s WHERE %[2]s IS NOT NULL", qtable, qcol),
+			Regex: re,
+		})
+	}
+	if cs.References != "" {
+		idx := strings.LastIndex(cs.References, ".")
+		if idx <= 0 {
+			return nil, fmt.Errorf("%s.%s: references has to be given as [schema.]table.column, got %q", table, col, cs.References)
+		}
+		refTable, refCol := cs.References[:idx], cs.References[idx+1:]
+		add("references", fmt.Sprintf("%s references %s", col, cs.References),
+			fmt.Sprintf("SELECT t.* FROM %s t WHERE t.%[2]s IS NOT NULL AND NOT EXISTS (SELECT 1 FROM %[3]s r WHERE r.%[4]s = t.%[2]s)",
+				qtable, qcol, mssqlload.QuoteTableName(refTable), mssqlload.QuoteName(refCol)))
+	}
+	return checks, nil
+}
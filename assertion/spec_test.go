@@ -0,0 +1,94 @@
+package assertion
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+const testSpec = `
+tables:
+  pokemon.pokemon:
+    min_rows: 1
+    columns:
+      name: {not_null: true, unique: true, regex: "^[A-Z]"}
+      hp: {min: 1, max: 255}
+      evolved_from: {references: pokemon.pokemon.name}
+    sql:
+      - query: SELECT * FROM pokemon.pokemon WHERE hp IS NULL
+`
+
+func TestChecks(t *testing.T) {
+	spec, err := ParseSpec([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, err := spec.Checks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, c := range checks {
+		kinds = append(kinds, c.Column+":"+c.Kind)
+	}
+	expected := ":min_rows evolved_from:references hp:range name:not_null name:unique name:regex :sql"
+	if got := strings.Join(kinds, " "); got != expected {
+		t.Fatalf("expected checks %q, got %q", expected, got)
+	}
+
+	ref := checks[1].Query
+	if !strings.Contains(ref, "NOT EXISTS (SELECT 1 FROM [pokemon].[pokemon] r WHERE r.[name] = t.[evolved_from])") {
+		t.Errorf("unexpected references query %s", ref)
+	}
+	if rng := checks[2]; rng.Query != "SELECT * FROM [pokemon].[pokemon] WHERE [hp] < @p1 OR [hp] > @p2" || len(rng.Args) != 2 {
+		t.Errorf("unexpected range check %+v", rng)
+	}
+	if re := checks[5]; re.Query != "SELECT [name] FROM [pokemon].[pokemon] WHERE [name] IS NOT NULL" {
+		t.Errorf("unexpected regex query %s", re.Query)
+	}
+	if checks[6].Name != "sql 1" {
+		t.Errorf("expected default name for sql check, got %s", checks[6].Name)
+	}
+
+	if _, err := ParseSpec([]byte(`{"tables": {}}`)); err == nil {
+		t.Error("expected error for empty spec")
+	}
+	spec, _ = ParseSpec([]byte(`{"tables": {"t": {"columns": {"c": {"references": "t"}}}}}`))
+	if _, err := spec.Checks(); err == nil {
+		t.Error("expected error for invalid reference")
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := []Result{
+		{Check: Check{Table: "pokemon.pokemon", Name: "name is not null", Kind: "not_null"}, Passed: true},
+		{
+			Check:       Check{Table: "pokemon.pokemon", Name: "hp >= 1", Kind: "range", Query: "SELECT 1"},
+			FailingRows: 2,
+			Samples:     []map[string]any{{"name": "Wartortle", "hp": 0}},
+		},
+		{Check: Check{Table: "pokemon.types", Name: "sql 1", Kind: "sql"}, Error: "invalid object name"},
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if len(suites.Suites) != 2 {
+		t.Fatalf("expected a suite per table, got %d", len(suites.Suites))
+	}
+	s := suites.Suites[0]
+	if s.Tests != 2 || s.Failures != 1 || s.Errors != 0 {
+		t.Errorf("unexpected counts %+v", s)
+	}
+	if f := s.Cases[1].Failure; f == nil || !strings.Contains(f.Body, `{"hp":0,"name":"Wartortle"}`) {
+		t.Errorf("expected sample rows in failure, got %+v", f)
+	}
+	if suites.Suites[1].Errors != 1 {
+		t.Errorf("expected error in second suite")
+	}
+}
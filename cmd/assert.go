@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jwbargsten/go-mssql-load/assertion"
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	assertCmd.Flags().String("report", "text", "Report format: text, json or junit")
+	assertCmd.Flags().StringP("output", "o", "", "Writes the report to a file instead of STDOUT")
+	assertCmd.Flags().Int("samples", 5, "Maximum number of failing rows reported per check")
+	rootCmd.AddCommand(assertCmd)
+}
+
+var assertCmd = &cobra.Command{
+	Use:   "assert <spec>",
+	Short: "Run data quality checks",
+	Long: `Run data quality checks
+
+The checks are defined per table in a YAML (or JSON) spec:
+
+  tables:
+    pokemon.pokemon:
+      min_rows: 1
+      columns:
+        name: {not_null: true, unique: true, regex: "^[A-Z]"}
+        hp: {min: 1, max: 255}
+        evolved_from: {references: pokemon.pokemon.name}
+      sql:
+        - name: legendary pokemon are strong
+          query: SELECT * FROM pokemon.pokemon WHERE legendary = 1 AND hp < 100
+
+Every check is turned into a query returning the failing rows; custom sql
+checks have to return zero rows. The report (text, json or junit) contains
+the number of failing rows and a sample of them. The exit code is 1 if a
+check fails.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		dsn, err := buildDSN(flags)
+		if err != nil {
+			log.Errorw("could not build DSN", zap.Error(err))
+			return err
+		}
+		report, _ := flags.GetString("report")
+		writeReport, ok := reportWriters[report]
+		if !ok {
+			return fmt.Errorf("unknown report format %q (supported: text, json, junit)", report)
+		}
+		samples, _ := flags.GetInt("samples")
+
+		spec, err := assertion.LoadSpec(args[0])
+		if err != nil {
+			return fmt.Errorf("could not load spec: %w", err)
+		}
+		checks, err := spec.Checks()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+		con, err := db.Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer con.Close()
+
+		log.Infof("running %d checks", len(checks))
+		results, err := assertion.Run(ctx, con.DB, checks, assertion.RunOptions{Samples: samples})
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if out, _ := flags.GetString("output"); out != "" {
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := writeReport(w, results); err != nil {
+			return err
+		}
+		if failed := assertion.Failed(results); failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(results))
+		}
+		log.Infof("all %d checks passed", len(results))
+		return nil
+	},
+}
+
+var reportWriters = map[string]func(io.Writer, []assertion.Result) error{
+	"text":  writeTextReport,
+	"json":  assertion.WriteJSON,
+	"junit": assertion.WriteJUnit,
+}
+
+func writeTextReport(w io.Writer, results []assertion.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		status, detail := "PASS", ""
+		switch {
+		case r.Error != "":
+			status, detail = "ERROR", r.Error
+		case !r.Passed:
+			status, detail = "FAIL", fmt.Sprintf("%d failing rows", r.FailingRows)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", status, r.Table, r.Name, detail)
+	}
+	return tw.Flush()
+}
@@ -2,40 +2,124 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/jwbargsten/go-mssql-load/db"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
 )
 
+const defaultWaitTimeout = 90 * time.Second
+
 func init() {
+	checkCmd.Flags().Bool("wait", false, "Wait until the db is available (or --timeout is reached)")
+	checkCmd.Flags().Duration("interval", time.Second, "Initial retry interval, only used with --wait")
+	checkCmd.Flags().Duration("max-interval", 10*time.Second, "Maximum retry interval, only used with --wait")
+	checkCmd.Flags().String("wait-database", "", "Also wait until this database is ONLINE")
+	checkCmd.Flags().String("wait-object", "", `Also wait until this object (e.g. a table) exists. Unless
+fully qualified, it is resolved in --wait-database`)
+	checkCmd.Flags().BoolP("verbose", "v", false, "Print server, session, TLS and permission diagnostics")
+	checkCmd.Flags().Bool("json", false, "Print the diagnostics as JSON")
 	rootCmd.AddCommand(checkCmd)
 }
 
 var checkCmd = &cobra.Command{
-	Use:   "check [flags] <path>",
+	Use:   "check [flags]",
 	Short: "Test db connection",
-	Long:  `Test db connection.`,
+	Long: `Test db connection.
+
+Without --wait, the connection is retried a few times within ~2 seconds.
+With --wait, the connection is retried with exponential backoff (starting
+at --interval, capped at --max-interval) until it succeeds or --timeout (default
+90s) is reached. Authentication failures are never retried.
+
+With --verbose or --json, diagnostics are printed to STDOUT after a
+successful connection: server version and edition, login, database,
+collation, compatibility level, round trip latency, encryption and TLS
+details and whether the login may create tables and do bulk operations.
+Each item is reported as pass or fail; failing items do not change the
+exit code.
+
+Exit codes: 3 on timeout, 4 on authentication failure, 5 on network failure,
+130 if cancelled.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dsn, err := buildDSN(cmd.Flags())
+		flags := cmd.Flags()
+		dsn, err := buildDSN(flags)
 		if err != nil {
 			log.Errorw("could not build DSN", zap.Error(err))
 			return err
 		}
 
+		opts := db.WaitOptions{Backoff: db.DefaultBackoff, MaxAttempts: 6}
+		opts.Database, _ = flags.GetString("wait-database")
+		opts.Object, _ = flags.GetString("wait-object")
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+		if wait, _ := flags.GetBool("wait"); wait {
+			timeout, _ := flags.GetDuration("timeout")
+			if timeout <= 0 {
+				timeout = defaultWaitTimeout
+				var cancelWait context.CancelFunc
+				ctx, cancelWait = context.WithTimeout(ctx, timeout)
+				defer cancelWait()
+			}
+			opts.Backoff.Initial, _ = flags.GetDuration("interval")
+			opts.Backoff.Max, _ = flags.GetDuration("max-interval")
+			opts.MaxAttempts = 0
+			log.Infof("waiting up to %s for %s/%s", timeout, dsn.Host, dsn.Query().Get("database"))
+		} else {
+			log.Infof("checking connection to %s/%s", dsn.Host, dsn.Query().Get("database"))
+		}
 
-		log.Infof("checking connection to %s/%s", dsn.Host, dsn.Query().Get("database"))
-		ctx := context.Background()
 		con, err := db.Open(dsn)
 		if err != nil {
 			log.Errorw("could not connect to db", zap.Error(err))
 			return err
 		}
-		err = db.StatusCheck(ctx, con, log)
+		defer con.Close()
+		err = db.Wait(ctx, con, log, opts)
 		if err != nil {
 			log.Errorw("could not connect to db", zap.Error(err))
 			return err
 		}
 		log.Infof("connection successful!")
-		return nil
+
+		verbose, _ := flags.GetBool("verbose")
+		asJSON, _ := flags.GetBool("json")
+		if !verbose && !asJSON {
+			return nil
+		}
+		items, err := db.Diagnose(ctx, dsn)
+		if err != nil {
+			log.Errorw("could not collect diagnostics", zap.Error(err))
+			return err
+		}
+		return writeCheckItems(os.Stdout, items, asJSON)
 	},
 }
+
+// writeCheckItems prints the diagnostics as JSON or as table with one item
+// per line.
+func writeCheckItems(w io.Writer, items []db.CheckItem, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(items)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, it := range items {
+		status, value := "PASS", it.Value
+		if !it.OK {
+			status = "FAIL"
+		}
+		if it.Error != "" {
+			value = it.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", status, it.Name, value)
+	}
+	return tw.Flush()
+}
@@ -1,13 +1,19 @@
 package cmd
 
 import (
-	"github.com/jwbargsten/go-mssql-load/internal/testkit"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqltest"
+	"os"
+	"syscall"
 	"testing"
 )
 
 func TestLoadSql(t *testing.T) {
-	conn := testkit.OpenTestDB(t, "master")
-	defer conn.Close()
+	conn := mssqltest.Open(t, "master")
 
 	args := []string{ "loadsql", "../sql/init.sql"}
 	rootCmd.SetArgs(args)
@@ -45,3 +51,54 @@ func TestCheck(t *testing.T) {
 	}
 
 }
+
+func TestExitCode(t *testing.T) {
+	cases := map[error]int{
+		errors.New("boom"):                                  exitError,
+		fmt.Errorf("batch 2: %w", context.Canceled):         exitCanceled,
+		fmt.Errorf("batch 1: %w", context.DeadlineExceeded): exitTimeout,
+		fmt.Errorf("%w: login failed", db.ErrAuth):          exitAuth,
+	}
+	for err, expected := range cases {
+		if code := exitCode(err); code != expected {
+			t.Errorf("expected exit code %d for %q, got %d", expected, err, code)
+		}
+	}
+
+	receivedSignal.Store(os.Signal(syscall.SIGTERM))
+	defer receivedSignal.Store(os.Signal(os.Interrupt))
+	if code := exitCode(context.Canceled); code != exitTerminated {
+		t.Errorf("expected exit code %d after SIGTERM, got %d", exitTerminated, code)
+	}
+}
+
+func TestWriteCheckItems(t *testing.T) {
+	items := []db.CheckItem{
+		{Name: "latency", OK: true, Value: "1.2ms"},
+		{Name: "tls", OK: false, Error: "no TLS handshake took place"},
+		{Name: "permission CREATE TABLE", OK: false, Value: "denied"},
+	}
+	cases := []struct {
+		name     string
+		asJSON   bool
+		expected string
+	}{
+		{"table", false, "PASS  latency                  1.2ms\n" +
+			"FAIL  tls                      no TLS handshake took place\n" +
+			"FAIL  permission CREATE TABLE  denied\n"},
+		{"json", true, `[{"name":"latency","ok":true,"value":"1.2ms"},` +
+			`{"name":"tls","ok":false,"error":"no TLS handshake took place"},` +
+			`{"name":"permission CREATE TABLE","ok":false,"value":"denied"}]` + "\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeCheckItems(&buf, items, c.asJSON); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != c.expected {
+				t.Errorf("expected\n%s\ngot\n%s", c.expected, buf.String())
+			}
+		})
+	}
+}
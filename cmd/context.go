@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// receivedSignal is the signal that cancelled the command context, see
+// exitCode.
+var receivedSignal atomic.Value
+
+// commandContext returns the context for the DB calls of a command. It is
+// cancelled on SIGINT/SIGTERM and after --timeout (if set). After the first
+// signal the default handling is restored, so a second Ctrl-C terminates the
+// process immediately.
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	parent, cancelTimeout := cmd.Context(), context.CancelFunc(func() {})
+	if timeout, _ := cmd.Flags().GetDuration("timeout"); timeout > 0 {
+		parent, cancelTimeout = context.WithTimeout(parent, timeout)
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case s := <-sigs:
+			signal.Stop(sigs)
+			receivedSignal.Store(s)
+			log.Warnw("received signal, cancelling", "signal", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+		cancelTimeout()
+	}
+}
+
+// statementTimeout returns the value of --statement-timeout.
+func statementTimeout(cmd *cobra.Command) time.Duration {
+	v, _ := cmd.Flags().GetDuration("statement-timeout")
+	return v
+}
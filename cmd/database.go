@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/schema"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	for _, c := range []*cobra.Command{dbCreateCmd, dbResetCmd} {
+		c.Flags().String("collation", "", "Collation of the database (default: server collation)")
+		c.Flags().String("recovery-model", "", "Recovery model: simple, full or bulk_logged (default: as model database)")
+	}
+	for _, c := range []*cobra.Command{dbDropCmd, dbResetCmd} {
+		c.Flags().Bool("force", false, `Disconnects other sessions and rolls back their
+transactions (SINGLE_USER WITH ROLLBACK IMMEDIATE)`)
+	}
+	dbResetCmd.Flags().StringSlice("seed", nil, `SQL or CSV files that are loaded in order after
+creating the database. CSV files are loaded into
+the table named like the file, e.g. pokemon.pokemon.csv`)
+	dbCloneCmd.Flags().String("method", "backup", `backup (BACKUP/RESTORE, copies everything) or
+copy (tables, indexes, constraints, views and rows)`)
+	dbCloneCmd.Flags().String("backup-dir", "", `Directory on the server for the backup, used with
+--method backup (default: instance backup directory)`)
+	dbCloneCmd.Flags().Bool("force", false, "Drops the target database first, if it exists")
+
+	dbCmd.AddCommand(dbCreateCmd, dbDropCmd, dbResetCmd, dbCloneCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Create, drop, reset and clone databases",
+	Long: `Create, drop, reset and clone databases
+
+The commands connect to the master database of the server, independent of
+--name. System databases cannot be created or dropped. With --dry-run,
+nothing is changed.`,
+}
+
+var dbCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a database",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := createOptions(cmd)
+		return withAdmin(cmd, func(ctx context.Context, dsn *url.URL, admin *sqlx.DB) error {
+			if dryRun(cmd) {
+				log.Infof("dry run: would create database %s", args[0])
+				return nil
+			}
+			if err := db.CreateDatabase(ctx, admin, args[0], opts); err != nil {
+				return err
+			}
+			log.Infof("created database %s", args[0])
+			return nil
+		})
+	},
+}
+
+var dbDropCmd = &cobra.Command{
+	Use:   "drop <name>",
+	Short: "Drop a database",
+	Long: `Drop a database
+
+Dropping a database that does not exist is not an error. Without --force,
+the drop fails if the database is in use.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		return withAdmin(cmd, func(ctx context.Context, dsn *url.URL, admin *sqlx.DB) error {
+			return dropDatabase(ctx, cmd, admin, args[0], force)
+		})
+	},
+}
+
+var dbResetCmd = &cobra.Command{
+	Use:   "reset <name>",
+	Short: "Drop, create and seed a database",
+	Long: `Drop, create and seed a database
+
+The database is dropped (if it exists) and created again. Then the --seed
+files are loaded in order: SQL files like with loadsql, CSV files like with
+loadcsv into the table named like the file without the extension, e.g.
+pokemon.pokemon.csv into pokemon.pokemon. Column types can be given in the
+CSV header, e.g. "id::int".`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := createOptions(cmd)
+		force, _ := cmd.Flags().GetBool("force")
+		seeds, _ := cmd.Flags().GetStringSlice("seed")
+		for _, f := range seeds {
+			if ext := strings.ToLower(filepath.Ext(f)); ext != ".sql" && ext != ".csv" {
+				return fmt.Errorf("seed file %s is neither .sql nor .csv", f)
+			}
+			if _, err := os.Stat(f); err != nil {
+				return err
+			}
+		}
+		name := args[0]
+		return withAdmin(cmd, func(ctx context.Context, dsn *url.URL, admin *sqlx.DB) error {
+			if err := dropDatabase(ctx, cmd, admin, name, force); err != nil {
+				return err
+			}
+			if dryRun(cmd) {
+				log.Infof("dry run: would create database %s and load %d seed files", name, len(seeds))
+				return nil
+			}
+			if err := db.CreateDatabase(ctx, admin, name, opts); err != nil {
+				return err
+			}
+			log.Infof("created database %s", name)
+			if len(seeds) == 0 {
+				return nil
+			}
+			con, err := db.Open(db.WithDatabase(dsn, name))
+			if err != nil {
+				return err
+			}
+			defer con.Close()
+			for _, f := range seeds {
+				if err := loadSeed(ctx, cmd, con, f); err != nil {
+					return fmt.Errorf("could not load %s: %w", f, err)
+				}
+			}
+			log.Infof("loaded %d seed files", len(seeds))
+			return nil
+		})
+	},
+}
+
+var dbCloneCmd = &cobra.Command{
+	Use:   "clone <src> <dst>",
+	Short: "Copy a database on the same server",
+	Long: `Copy a database on the same server
+
+--method backup backs up src with COPY_ONLY (the backup chain is not
+affected) and restores it as dst. The backup file stays in --backup-dir,
+which has to be writable by the server.
+
+--method copy creates dst with the collation of src, creates the tables,
+indexes, constraints and views and copies the rows. Procedures, functions,
+triggers, users and permissions are not copied. Use it if backups are not
+possible, e.g. without the needed permissions.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		method, _ := flags.GetString("method")
+		if method != "backup" && method != "copy" {
+			return fmt.Errorf("invalid method %q, expected backup or copy", method)
+		}
+		backupDir, _ := flags.GetString("backup-dir")
+		force, _ := flags.GetBool("force")
+		src, dst := args[0], args[1]
+		if strings.EqualFold(src, dst) {
+			return fmt.Errorf("source and target database are the same")
+		}
+		return withAdmin(cmd, func(ctx context.Context, dsn *url.URL, admin *sqlx.DB) error {
+			exists, err := db.DatabaseExists(ctx, admin, dst)
+			if err != nil {
+				return err
+			}
+			if exists {
+				if !force {
+					return fmt.Errorf("database %s exists, use --force to replace it", dst)
+				}
+				if err := dropDatabase(ctx, cmd, admin, dst, true); err != nil {
+					return err
+				}
+			}
+			if dryRun(cmd) {
+				log.Infof("dry run: would clone %s to %s with method %s", src, dst, method)
+				return nil
+			}
+			log.Infof("cloning %s to %s with method %s", src, dst, method)
+			if method == "backup" {
+				err = db.CloneDatabase(ctx, admin, src, dst, backupDir)
+			} else {
+				err = copyDatabase(ctx, cmd, dsn, admin, src, dst)
+			}
+			if err != nil {
+				return err
+			}
+			log.Infof("cloned %s to %s", src, dst)
+			return nil
+		})
+	},
+}
+
+func createOptions(cmd *cobra.Command) db.CreateOptions {
+	var opts db.CreateOptions
+	opts.Collation, _ = cmd.Flags().GetString("collation")
+	opts.RecoveryModel, _ = cmd.Flags().GetString("recovery-model")
+	return opts
+}
+
+// withAdmin runs fn with a connection to the master database.
+func withAdmin(cmd *cobra.Command, fn func(ctx context.Context, dsn *url.URL, admin *sqlx.DB) error) error {
+	dsn, err := buildDSN(cmd.Flags())
+	if err != nil {
+		log.Errorw("could not build DSN", zap.Error(err))
+		return err
+	}
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+	admin, err := db.Open(db.WithDatabase(dsn, "master"))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	return fn(ctx, dsn, admin)
+}
+
+func dropDatabase(ctx context.Context, cmd *cobra.Command, admin *sqlx.DB, name string, force bool) error {
+	if dryRun(cmd) {
+		log.Infof("dry run: would drop database %s", name)
+		return nil
+	}
+	dropped, err := db.DropDatabase(ctx, admin, name, force)
+	if err != nil {
+		if !force {
+			return fmt.Errorf("could not drop database %s (use --force if it is in use): %w", name, err)
+		}
+		return err
+	}
+	if dropped {
+		log.Infof("dropped database %s", name)
+	} else {
+		log.Infof("database %s does not exist", name)
+	}
+	return nil
+}
+
+// loadSeed loads a seed file of db reset, see its help.
+func loadSeed(ctx context.Context, cmd *cobra.Command, con *sqlx.DB, f string) error {
+	fp, err := os.Open(f)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	if strings.EqualFold(filepath.Ext(f), ".sql") {
+		log.Infof("loading sql file %s", f)
+		_, err := mssqlload.LoadSQL(ctx, con.DB, fp, mssqlload.LoadSQLOptions{
+			StatementTimeout: statementTimeout(cmd),
+			Log:              log,
+		})
+		return err
+	}
+	table := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+	log.Infof("loading csv file %s into %s", f, table)
+	res, err := mssqlload.LoadCSV(ctx, con.DB, table, fp, mssqlload.CSVOptions{Log: log})
+	if err != nil {
+		return err
+	}
+	log.Infof("inserted %d rows", res.Inserted)
+	return nil
+}
+
+// copyDatabase clones src to dst by creating the schema and copying the
+// rows. dst is dropped again if the copy fails.
+func copyDatabase(ctx context.Context, cmd *cobra.Command, dsn *url.URL, admin *sqlx.DB, src, dst string) error {
+	collation, err := db.DatabaseCollation(ctx, admin, src)
+	if err != nil {
+		return err
+	}
+	s, err := introspect(ctx, db.WithDatabase(dsn, src))
+	if err != nil {
+		return err
+	}
+	var script bytes.Buffer
+	if err := schema.WriteCopy(&script, s, src); err != nil {
+		return err
+	}
+	if err := db.CreateDatabase(ctx, admin, dst, db.CreateOptions{Collation: collation}); err != nil {
+		return err
+	}
+
+	con, err := db.Open(db.WithDatabase(dsn, dst))
+	if err == nil {
+		_, err = mssqlload.LoadSQL(ctx, con.DB, &script, mssqlload.LoadSQLOptions{
+			StatementTimeout: statementTimeout(cmd),
+			Log:              log,
+		})
+		// close the connections to dst before dropping it
+		con.Close()
+	}
+	if err != nil {
+		// ctx might be cancelled already, the half copied database has to go anyway
+		if _, dropErr := db.DropDatabase(context.Background(), admin, dst, true); dropErr != nil {
+			log.Errorw("could not drop database", "name", dst, zap.Error(dropErr))
+		}
+		return err
+	}
+	return nil
+}
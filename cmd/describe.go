@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/schema"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	describeCmd.PersistentFlags().String("format", "table", "Output format: table, json or csv")
+	describeCmd.AddCommand(
+		describeQueryCmd("schemas", "List schemas", describeSchemasQuery, cobra.NoArgs),
+		describeQueryCmd("tables [schema]", "List tables with their row counts", describeTablesQuery, cobra.MaximumNArgs(1)),
+		describeTableCmd,
+		describeQueryCmd("indexes [table]", "List indexes", describeIndexesQuery, cobra.MaximumNArgs(1)),
+		describeQueryCmd("fks [table]", "List foreign keys", describeFKsQuery, cobra.MaximumNArgs(1)),
+	)
+	rootCmd.AddCommand(describeCmd)
+}
+
+var describeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Describe schemas, tables, indexes and foreign keys",
+	Long: `Describe schemas, tables, indexes and foreign keys
+
+The information is read from the sys.* catalog views. With --format json,
+the output is newline delimited JSON like the output of querysql, e.g. to
+generate a types file for loadcsv from "describe table".`,
+}
+
+// The queries take the filter argument as @p1 and, for tables, the schema
+// and the table name as @p2 and @p3.
+const describeSchemasQuery = `SELECT s.name AS [schema], p.name AS [owner],
+  (SELECT COUNT(*) FROM sys.tables t WHERE t.schema_id = s.schema_id) AS [tables]
+FROM sys.schemas s
+JOIN sys.database_principals p ON p.principal_id = s.principal_id
+WHERE s.schema_id < 16384 AND s.name NOT IN ('sys', 'INFORMATION_SCHEMA', 'guest')
+ORDER BY s.name`
+
+const describeTablesQuery = `SELECT s.name AS [schema], t.name AS [table], SUM(p.rows) AS [rows],
+  t.create_date AS [created], t.modify_date AS [modified]
+FROM sys.tables t
+JOIN sys.schemas s ON s.schema_id = t.schema_id
+JOIN sys.partitions p ON p.object_id = t.object_id AND p.index_id IN (0, 1)
+WHERE t.is_ms_shipped = 0 AND (@p1 = '' OR s.name = @p1)
+GROUP BY s.name, t.name, t.create_date, t.modify_date
+ORDER BY s.name, t.name`
+
+// The column lists are aggregated with FOR XML PATH instead of STRING_AGG,
+// which needs SQL Server 2017.
+const describeIndexesQuery = `SELECT s.name AS [schema], t.name AS [table], i.name AS [index],
+  i.type_desc AS [type], i.is_unique AS [unique], i.is_primary_key AS [primary],
+  STUFF((
+    SELECT ', ' + c.name
+    FROM sys.index_columns ic
+    JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+    WHERE ic.object_id = i.object_id AND ic.index_id = i.index_id AND ic.is_included_column = 0
+    ORDER BY ic.key_ordinal
+    FOR XML PATH(''), TYPE).value('.', 'nvarchar(max)'), 1, 2, '') AS [columns]
+FROM sys.indexes i
+JOIN sys.tables t ON t.object_id = i.object_id
+JOIN sys.schemas s ON s.schema_id = t.schema_id
+WHERE t.is_ms_shipped = 0 AND i.type > 0 AND (@p1 = '' OR (s.name = @p2 AND t.name = @p3))
+ORDER BY s.name, t.name, i.name`
+
+const describeFKsQuery = `SELECT s.name AS [schema], t.name AS [table], fk.name AS [foreign_key],
+  STUFF((
+    SELECT ', ' + pc.name
+    FROM sys.foreign_key_columns fkc
+    JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+    WHERE fkc.constraint_object_id = fk.object_id
+    ORDER BY fkc.constraint_column_id
+    FOR XML PATH(''), TYPE).value('.', 'nvarchar(max)'), 1, 2, '') AS [columns],
+  rs.name + '.' + rt.name AS [references],
+  STUFF((
+    SELECT ', ' + rc.name
+    FROM sys.foreign_key_columns fkc
+    JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+    WHERE fkc.constraint_object_id = fk.object_id
+    ORDER BY fkc.constraint_column_id
+    FOR XML PATH(''), TYPE).value('.', 'nvarchar(max)'), 1, 2, '') AS [referenced_columns],
+  fk.delete_referential_action_desc AS [on_delete], fk.update_referential_action_desc AS [on_update]
+FROM sys.foreign_keys fk
+JOIN sys.tables t ON t.object_id = fk.parent_object_id
+JOIN sys.schemas s ON s.schema_id = t.schema_id
+JOIN sys.tables rt ON rt.object_id = fk.referenced_object_id
+JOIN sys.schemas rs ON rs.schema_id = rt.schema_id
+WHERE t.is_ms_shipped = 0 AND (@p1 = '' OR (s.name = @p2 AND t.name = @p3))
+ORDER BY s.name, t.name, fk.name`
+
+func describeQueryCmd(use string, short string, query string, args cobra.PositionalArgs) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  args,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			dsn, err := buildDSN(flags)
+			if err != nil {
+				log.Errorw("could not build DSN", zap.Error(err))
+				return err
+			}
+			v, _ := flags.GetString("format")
+			format, err := mssqlload.ParseFormat(v)
+			if err != nil {
+				return err
+			}
+			filter, schemaName, table := describeFilter(args)
+
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+			con, err := db.Open(dsn)
+			if err != nil {
+				return err
+			}
+			defer con.Close()
+
+			rows, err := con.QueryContext(ctx, query, filter, schemaName, table)
+			if err != nil {
+				return mssqlload.ContextError(ctx, err)
+			}
+			defer rows.Close()
+			_, err = mssqlload.WriteRows(os.Stdout, rows, format)
+			return mssqlload.ContextError(ctx, err)
+		},
+	}
+}
+
+// describeFilter returns the query arguments for the optional filter
+// argument: the argument itself and, for tables, the schema (dbo if not
+// given) and the table name.
+func describeFilter(args []string) (string, string, string) {
+	if len(args) == 0 {
+		return "", "", ""
+	}
+	schemaName, table := mssqlload.SplitTableName(args[0])
+	return args[0], schemaName, table
+}
+
+var describeTableCmd = &cobra.Command{
+	Use:   "table <table>",
+	Short: "List the columns of a table",
+	Long: `List the columns of a table
+
+The table is given as schema.table, without schema it is looked up in dbo.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		dsn, err := buildDSN(flags)
+		if err != nil {
+			log.Errorw("could not build DSN", zap.Error(err))
+			return err
+		}
+		v, _ := flags.GetString("format")
+		format, err := mssqlload.ParseFormat(v)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+		con, err := db.Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer con.Close()
+
+		t, err := schema.IntrospectTable(ctx, con.DB, args[0])
+		if err != nil {
+			return mssqlload.ContextError(ctx, err)
+		}
+		return mssqlload.WriteResultSet(os.Stdout, describeColumns(t), format)
+	},
+}
+
+// describeColumns lists the columns of t with their types, formatted like
+// in CREATE TABLE.
+func describeColumns(t *schema.Table) mssqlload.ResultSet {
+	rs := mssqlload.ResultSet{Columns: []string{"idx", "column", "type", "nullable", "identity", "computed", "default"}}
+	for i, c := range t.Columns {
+		var def any
+		if c.Default != "" {
+			def = c.Default
+		}
+		rs.Rows = append(rs.Rows, []any{int64(i + 1), c.Name, c.Type, c.Nullable, c.Identity, c.Computed, def})
+	}
+	return rs
+}
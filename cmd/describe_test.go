@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/schema"
+)
+
+func TestDescribeFilter(t *testing.T) {
+	cases := []struct {
+		args                  []string
+		filter, schema, table string
+	}{
+		{nil, "", "", ""},
+		{[]string{"pokemon"}, "pokemon", "dbo", "pokemon"},
+		{[]string{"pokemon.moves"}, "pokemon.moves", "pokemon", "moves"},
+		{[]string{"[poke.mon].[moves]"}, "[poke.mon].[moves]", "poke.mon", "moves"},
+	}
+	for _, c := range cases {
+		filter, schemaName, table := describeFilter(c.args)
+		if filter != c.filter || schemaName != c.schema || table != c.table {
+			t.Errorf("%v: got %q %q %q", c.args, filter, schemaName, table)
+		}
+	}
+}
+
+func TestDescribeColumns(t *testing.T) {
+	table := &schema.Table{
+		Name: "pokemon.pokemon",
+		Columns: []schema.Column{
+			{Name: "id", Type: schema.TypeName("int", 4, 10, 0), Identity: true},
+			{Name: "name", Type: schema.TypeName("nvarchar", 100, 0, 0)},
+			{Name: "notes", Type: schema.TypeName("nvarchar", -1, 0, 0), Nullable: true},
+			{Name: "legendary", Type: schema.TypeName("bit", 1, 1, 0), Default: "((0))"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := mssqlload.WriteResultSet(&buf, describeColumns(table), mssqlload.FormatTable); err != nil {
+		t.Fatal(err)
+	}
+	expected := `idx  column     type           nullable  identity  computed  default
+---  ------     ----           --------  --------  --------  -------
+1    id         int            false     true      false     NULL
+2    name       nvarchar(50)   false     false     false     NULL
+3    notes      nvarchar(max)  true      false     false     NULL
+4    legendary  bit            false     false     false     ((0))
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+}
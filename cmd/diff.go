@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/schema"
+	"github.com/jwbargsten/go-mssql-load/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"net/url"
+	"os"
+)
+
+func init() {
+	diffCmd.Flags().String("to-dsn", "", "DSN of the database to compare with")
+	diffCmd.Flags().StringSlice("to-sql", nil, `SQL files that are loaded into a scratch database
+on the same server to compare with`)
+	diffCmd.Flags().String("migration", "", `Writes a migration script from the db to the
+compared schema to this file, "-" for STDOUT`)
+	diffCmd.Flags().Bool("exit-code", false, "Exits with 1 if there are differences")
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff (--to-dsn <dsn> | --to-sql <file>...)",
+	Short: "Compare the schema of the db with another db or SQL files",
+	Long: `Compare the schema of the db with another db or SQL files
+
+Tables, columns (type, nullability, identity, default), indexes, foreign
+keys, check constraints and views of the configured db are compared with
+those of the db given by --to-dsn, or of a scratch database that is
+populated from the --to-sql files (and dropped afterwards). The SQL files
+must not switch the database with USE.
+
+Lines starting with "+" are only in the compared schema, "-" only in the db
+and "~" differ. --migration writes a script that turns the schema of the db
+into the compared one. Review it before running it: changes that cannot be
+done safely, e.g. changed defaults, are only added as comments.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		dsn, err := buildDSN(flags)
+		if err != nil {
+			log.Errorw("could not build DSN", zap.Error(err))
+			return err
+		}
+		toDSN, _ := flags.GetString("to-dsn")
+		toSQL, _ := flags.GetStringSlice("to-sql")
+		if (toDSN == "") == (len(toSQL) == 0) {
+			return fmt.Errorf("exactly one of --to-dsn and --to-sql is required")
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		from, err := introspect(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		var to *schema.Schema
+		if toDSN != "" {
+			u, err := db.ParseDSN(toDSN)
+			if err != nil {
+				return fmt.Errorf("could not parse --to-dsn: %w", err)
+			}
+			if pass, ok := u.User.Password(); ok {
+				util.AddSecret(pass)
+			}
+			to, err = introspect(ctx, u)
+			if err != nil {
+				return err
+			}
+		} else {
+			to, err = introspectSQLFiles(ctx, dsn, toSQL)
+			if err != nil {
+				return err
+			}
+		}
+
+		changes := schema.Diff(from, to)
+		out, _ := flags.GetString("migration")
+		diffOut := os.Stdout
+		if out == "-" {
+			// keep STDOUT for the script
+			diffOut = os.Stderr
+		}
+		if err := schema.WriteDiff(diffOut, changes); err != nil {
+			return err
+		}
+		if out != "" {
+			if err := writeMigration(out, changes); err != nil {
+				return err
+			}
+		}
+		if len(changes) == 0 {
+			log.Infof("no differences")
+			return nil
+		}
+		if exitCode, _ := flags.GetBool("exit-code"); exitCode {
+			return fmt.Errorf("found %d differences", len(changes))
+		}
+		return nil
+	},
+}
+
+func introspect(ctx context.Context, dsn *url.URL) (*schema.Schema, error) {
+	con, err := db.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+	log.Infof("reading schema of %s/%s", dsn.Host, dsn.Query().Get("database"))
+	return schema.Introspect(ctx, con.DB)
+}
+
+// introspectSQLFiles loads the files into a scratch database and reads its
+// schema. The scratch database is dropped afterwards.
+func introspectSQLFiles(ctx context.Context, dsn *url.URL, files []string) (*schema.Schema, error) {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := "go_mssql_load_diff_" + hex.EncodeToString(suffix)
+
+	admin, err := db.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+	log.Infof("creating scratch database %s", name)
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+mssqlload.QuoteName(name)); err != nil {
+		return nil, fmt.Errorf("could not create scratch database: %w", err)
+	}
+	defer func() {
+		// ctx might be cancelled already, the scratch database has to go anyway
+		stmt := fmt.Sprintf("ALTER DATABASE %[1]s SET SINGLE_USER WITH ROLLBACK IMMEDIATE; DROP DATABASE %[1]s", mssqlload.QuoteName(name))
+		if _, err := admin.ExecContext(context.Background(), stmt); err != nil {
+			log.Errorw("could not drop scratch database", "name", name, zap.Error(err))
+		}
+	}()
+
+	scratchDSN := db.WithDatabase(dsn, name)
+	con, err := db.Open(scratchDSN)
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+	for _, f := range files {
+		fp, err := os.Open(f)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("loading sql file %s", f)
+		_, err = mssqlload.LoadSQL(ctx, con.DB, fp, mssqlload.LoadSQLOptions{Log: log})
+		fp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not load %s: %w", f, err)
+		}
+	}
+	s, err := schema.Introspect(ctx, con.DB)
+	// close the connections to the scratch database before dropping it
+	con.Close()
+	return s, err
+}
+
+func writeMigration(out string, changes []schema.Change) error {
+	if out == "-" {
+		return schema.WriteMigration(os.Stdout, changes)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := schema.WriteMigration(f, changes); err != nil {
+		return err
+	}
+	log.Infof("wrote migration script %s", out)
+	return f.Close()
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/spf13/cobra"
+)
+
+// dryRun returns the value of --dry-run.
+func dryRun(cmd *cobra.Command) bool {
+	v, _ := cmd.Flags().GetBool("dry-run")
+	return v
+}
+
+// printBatches writes the batches of script with their line ranges, in a
+// form that can be run again.
+func printBatches(w io.Writer, script string) (int, error) {
+	batches := mssqlload.SplitBatchLines(script)
+	for i, b := range batches {
+		lines := fmt.Sprintf("line %d", b.StartLine)
+		if b.EndLine != b.StartLine {
+			lines = fmt.Sprintf("lines %d-%d", b.StartLine, b.EndLine)
+		}
+		if _, err := fmt.Fprintf(w, "-- batch %d (%s)\n%s\nGO\n", i+1, lines, strings.TrimSpace(b.SQL)); err != nil {
+			return i, err
+		}
+	}
+	return len(batches), nil
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrintBatches(t *testing.T) {
+	var sb strings.Builder
+	n, err := printBatches(&sb, "SELECT 1\nGO\n\nSELECT 2\nFROM t\nGO\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 batches, got %d", n)
+	}
+	expected := "-- batch 1 (line 1)\nSELECT 1\nGO\n-- batch 2 (lines 4-5)\nSELECT 2\nFROM t\nGO\n"
+	if sb.String() != expected {
+		t.Errorf("expected %q, got %q", expected, sb.String())
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/generate"
+	"github.com/jwbargsten/go-mssql-load/schema"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	generateCmd.Flags().Int64("rows", 100, "Number of rows to generate")
+	generateCmd.Flags().Int64("seed", 0, "Seed of the random generator, the same seed generates the same rows (default: random)")
+	generateCmd.Flags().String("spec", "", "YAML file with generators per column")
+	rootCmd.AddCommand(generateCmd)
+}
+
+var generateCmd = &cobra.Command{
+	Use:   "generate <table>",
+	Short: "Generate synthetic rows for a table",
+	Long: `Generate synthetic rows for a table
+
+The values are derived from the column types and constraints: nullable
+columns get some NULLs, strings fit the column length, unique and primary
+key columns get unique values without NULLs (strings get the row number
+appended), multi-column unique keys get unique combinations and foreign key columns get keys sampled from the
+referenced table.
+Identity, computed and rowversion columns are skipped.
+
+Columns can be customized with --spec:
+
+  columns:
+    name: {generator: name}
+    hp: {min: 1, max: 255, distribution: normal, mean: 80, stddev: 20}
+    type: {values: [fire, water, grass], weights: [1, 2, 1]}
+    legendary: {null_ratio: 0.5}
+    created_at: {skip: true}
+
+Generators are sequence, int, float, bool, word, sentence, name,
+first_name, last_name, email, city, uuid and date (min and max are years).
+
+The rows are inserted with bulk copy in one transaction. With --dry-run,
+the rows are generated, but not inserted.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		dsn, err := buildDSN(flags)
+		if err != nil {
+			log.Errorw("could not build DSN", zap.Error(err))
+			return err
+		}
+		n, _ := flags.GetInt64("rows")
+		seed, _ := flags.GetInt64("seed")
+		if !flags.Changed("seed") {
+			seed = time.Now().UnixNano()
+		}
+		var spec generate.Spec
+		if f, _ := flags.GetString("spec"); f != "" {
+			spec, err = generate.LoadSpec(f)
+			if err != nil {
+				log.Errorw("could not parse spec file", zap.Error(err))
+				return err
+			}
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+		con, err := db.Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer con.Close()
+		table, err := schema.IntrospectTable(ctx, con.DB, args[0])
+		if err != nil {
+			return err
+		}
+
+		opts := generate.Options{Seed: seed}
+		if err := generate.Prepare(ctx, con.DB, table, spec, &opts); err != nil {
+			return err
+		}
+		g, err := generate.New(table, spec, opts)
+		if err != nil {
+			return err
+		}
+		log.Infof("generating %d rows for %s with seed %d", n, table.Name, seed)
+		if dryRun(cmd) {
+			for i := int64(0); i < n; i++ {
+				if _, err := g.Row(i); err != nil {
+					return err
+				}
+			}
+			log.Infof("dry run: generated %d rows", n)
+			return nil
+		}
+		rows, err := generate.Load(ctx, con.DB, table.Name, g, n)
+		if err != nil {
+			return err
+		}
+		log.Infof("inserted %d rows", rows)
+		return nil
+	},
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/schema"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+)
+
+func init() {
+	gentypesCmd.Flags().String("form", "dict", "Form of the types file: dict (by column name) or list (by position)")
+	gentypesCmd.Flags().StringP("output", "o", "-", `Writes the types file to this file, "-" for STDOUT`)
+	gentypesCmd.Flags().String("csv-header", "", `Also writes a CSV header line (name::type!) to
+this file, "-" for STDOUT`)
+	gentypesCmd.Flags().String("sep", ",", "Separator of the CSV header")
+	rootCmd.AddCommand(gentypesCmd)
+}
+
+var gentypesCmd = &cobra.Command{
+	Use:   "gentypes <table>",
+	Short: "Generate a types file for loadcsv from a table",
+	Long: `Generate a types file for loadcsv from a table
+
+The columns of the table are mapped to the loadcsv types: integer types to
+int, float, decimal and money types to float, bit to bool and everything
+else to string. Nullable columns are marked with "!". Computed and
+rowversion columns are skipped, they cannot be loaded.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		dsn, err := buildDSN(flags)
+		if err != nil {
+			log.Errorw("could not build DSN", zap.Error(err))
+			return err
+		}
+		form, _ := flags.GetString("form")
+		if form != "dict" && form != "list" {
+			return fmt.Errorf("unknown form %q, expected dict or list", form)
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+		con, err := db.Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer con.Close()
+		table, err := schema.IntrospectTable(ctx, con.DB, args[0])
+		if err != nil {
+			return err
+		}
+
+		names, types := loaderColTypes(table.Columns)
+		var out []byte
+		if form == "list" {
+			out, err = json.MarshalIndent(types, "", "  ")
+		} else {
+			dict := make(map[string]string, len(names))
+			for i, n := range names {
+				dict[n] = types[i]
+			}
+			out, err = json.MarshalIndent(dict, "", "  ")
+		}
+		if err != nil {
+			return err
+		}
+		output, _ := flags.GetString("output")
+		if err := writeOutput(output, append(out, '\n')); err != nil {
+			return err
+		}
+
+		if headerFile, _ := flags.GetString("csv-header"); headerFile != "" {
+			sep, _ := flags.GetString("sep")
+			header := make([]string, len(names))
+			for i, n := range names {
+				header[i] = n + "::" + types[i]
+			}
+			if err := writeOutput(headerFile, []byte(strings.Join(header, sep)+"\n")); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// loaderColTypes returns the names and loadcsv types of the loadable
+// columns.
+func loaderColTypes(cols []schema.Column) ([]string, []string) {
+	var names, types []string
+	for _, c := range cols {
+		if c.Computed || c.Type == "timestamp" || c.Type == "rowversion" {
+			continue
+		}
+		t := mssqlload.LoaderType(c.Type)
+		if c.Nullable {
+			t += "!"
+		}
+		names = append(names, c.Name)
+		types = append(types, t)
+	}
+	return names, types
+}
+
+// writeOutput writes data to the file f, "-" is STDOUT.
+func writeOutput(f string, data []byte) error {
+	if f == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	log.Infof("writing %s", f)
+	return os.WriteFile(f, data, 0o644)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jwbargsten/go-mssql-load/schema"
+)
+
+func TestLoaderColTypes(t *testing.T) {
+	names, types := loaderColTypes([]schema.Column{
+		{Name: "id", Type: "int", Identity: true},
+		{Name: "name", Type: "varchar(255)"},
+		{Name: "hp", Type: "decimal(5,1)", Nullable: true},
+		{Name: "legendary", Type: "bit", Nullable: true},
+		{Name: "hp2", Type: "decimal(6,1)", Computed: true, Nullable: true},
+		{Name: "version", Type: "timestamp"},
+	})
+	if !reflect.DeepEqual(names, []string{"id", "name", "hp", "legendary"}) {
+		t.Errorf("unexpected names %v", names)
+	}
+	if !reflect.DeepEqual(types, []string{"int", "string", "float!", "bool!"}) {
+		t.Errorf("unexpected types %v", types)
+	}
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jwbargsten/go-mssql-load/config"
+	"github.com/jwbargsten/go-mssql-load/lint"
+	"github.com/jwbargsten/go-mssql-load/util"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	lintCmd.Flags().String("format", "text", "Output format: text or json")
+	lintCmd.Flags().StringToString("rule", nil, `Sets the severity of a rule: error, warning or
+off, e.g. --rule drop-if-exists=off`)
+	lintCmd.Flags().Bool("list-rules", false, "Lists the rules with their severities")
+	rootCmd.AddCommand(lintCmd)
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <path>...",
+	Short: "Check sql files for common problems",
+	Long: `Check sql files for common problems
+
+The files are checked offline, without connecting to the db, for problems
+that the batch splitting does not detect and that otherwise only surface
+on the server: unterminated strings and comments, GO inside strings and
+comments, CREATE VIEW/PROCEDURE/FUNCTION that are not first in their
+batch, DROP without IF EXISTS, USE in the middle of the script and sqlcmd
+directives. See --list-rules.
+
+The severity of the rules can be changed with --rule or in the lint
+section of the config file:
+
+  lint:
+    rules:
+      drop-if-exists: off
+      use-database: error
+
+The exit code is 1 if a finding has severity error.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if list, _ := cmd.Flags().GetBool("list-rules"); list {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		format, _ := flags.GetString("format")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("invalid format %q, expected text or json", format)
+		}
+		cfgFile, err := config.LoadFiles(configFiles(flags)...)
+		if err != nil {
+			return err
+		}
+		rules := cfgFile.Lint.Rules
+		if flags.Changed("rule") {
+			v, _ := flags.GetStringToString("rule")
+			if rules == nil {
+				rules = map[string]string{}
+			}
+			for name, severity := range v {
+				rules[name] = severity
+			}
+		}
+		cfg, err := lint.ParseConfig(rules)
+		if err != nil {
+			return err
+		}
+		if list, _ := flags.GetBool("list-rules"); list {
+			return writeRules(os.Stdout, cfg)
+		}
+
+		findings := []lint.Finding{}
+		for _, f := range args {
+			fp, err := util.OpenFileorStdin(f, log)
+			if err != nil {
+				return err
+			}
+			script, err := io.ReadAll(fp)
+			fp.Close()
+			if err != nil {
+				return err
+			}
+			for _, finding := range lint.Lint(string(script), cfg) {
+				finding.File = f
+				findings = append(findings, finding)
+			}
+		}
+
+		errors := 0
+		for _, f := range findings {
+			if f.Severity == lint.Error {
+				errors++
+			}
+		}
+		if format == "json" {
+			if err := json.NewEncoder(os.Stdout).Encode(findings); err != nil {
+				return err
+			}
+		} else {
+			for _, f := range findings {
+				fmt.Println(f)
+			}
+		}
+		log.Infof("checked %d files: %d errors, %d warnings", len(args), errors, len(findings)-errors)
+		if errors > 0 {
+			return fmt.Errorf("found %d lint errors", errors)
+		}
+		return nil
+	},
+}
+
+func writeRules(w io.Writer, cfg lint.Config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, r := range lint.Rules {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, cfg.Severity(r.Name), r.Description)
+	}
+	return tw.Flush()
+}
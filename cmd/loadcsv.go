@@ -1,21 +1,16 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/csv"
-	"encoding/json"
-	"errors"
+	"context"
+	"database/sql"
 	"fmt"
 	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
 	"github.com/jwbargsten/go-mssql-load/util"
-	mssql "github.com/microsoft/go-mssqldb"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"io"
-	"net/url"
-	"os"
-	"strconv"
-	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -24,295 +19,174 @@ func init() {
 	loadcsvCmd.Flags().String("nullstr", "", "if a column is nullable and its value is equal to this string, null is inferred")
 	loadcsvCmd.Flags().String("sep", ",", "separator")
 	loadcsvCmd.Flags().String("types", "", "file with types, takes precedence over CSV header types")
-}
-
-type ColTypes struct {
-	byName map[string]string
-	byPos  []string
-}
-
-func LoadColTypes(f string) (ColTypes, error) {
-	typesB, err := os.ReadFile(f)
-	if err != nil {
-		return ColTypes{}, err
-	}
-	// https://stackoverflow.com/questions/55014001/check-if-json-is-object-or-array
-	typesB = bytes.TrimLeft(typesB, " \t\r\n")
-
-	var colTypes ColTypes
-	isArray := len(typesB) > 0 && typesB[0] == '['
-	isObject := len(typesB) > 0 && typesB[0] == '{'
-
-	if isArray {
-		if err := json.Unmarshal(typesB, &colTypes.byPos); err != nil {
-			return ColTypes{}, err
-		}
-	} else if isObject {
-		if err := json.Unmarshal(typesB, &colTypes.byName); err != nil {
-			return ColTypes{}, err
-		}
-	} else {
-		return ColTypes{}, errors.New("JSON type file doesn't seem to have an array or dict structure")
-	}
-	return colTypes, err
-}
-
-func (ct ColTypes) FindByName(name string) (string, bool) {
-	if ct.byName == nil {
-		return "", false
-	}
-	v, ok := ct.byName[name]
-	return v, ok
-}
-func (ct ColTypes) FindByIdx(idx int) (string, bool) {
-	if ct.byPos == nil {
-		return "", false
-	}
-	if idx >= len(ct.byPos) || idx < 0 {
-		return "", false
-	}
-
-	v := ct.byPos[idx]
-	return v, true
-}
-
-func (ct ColTypes) Find(idx int, name string) (string, bool) {
-	if t, found := ct.FindByIdx(idx); found {
-		return t, true
-	}
-	if t, found := ct.FindByName(name); found {
-		return t, true
-	}
-	return "", false
+	loadcsvCmd.Flags().String("mode", "append", "append, truncate (TRUNCATE TABLE first), replace (DELETE first) or upsert (MERGE by --key)")
+	loadcsvCmd.Flags().StringSlice("key", nil, "key columns of upsert")
+	loadcsvCmd.Flags().Bool("delete-missing", false, "upsert deletes rows that are not in the csv file")
+	loadcsvCmd.Flags().String("metrics-file", "", `write load statistics as JSON to this file, "-" for STDOUT`)
+	loadcsvCmd.Flags().Bool("keep-identity", false, "load the values of the identity column (IDENTITY_INSERT) and reseed it")
 }
 
 var loadcsvCmd = &cobra.Command{
 	Use:   "loadcsv <table> <path>",
 	Short: "Load a csv file into the db",
-	Long:  `Load a csv file into the db`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Load a csv file into the db
+
+By default the rows are appended to the table. To reload a fixture,
+--mode truncate or --mode replace empty the table first, and --mode upsert
+loads the rows into a staging table and merges them into the table by the
+--key columns: new rows are inserted and changed rows updated. With
+--delete-missing, rows that are not in the file are deleted. Everything
+happens in one transaction.
+
+The server generates the values of identity columns, unless --keep-identity
+is given. Then the rows are inserted with IDENTITY_INSERT and the identity
+is reseeded afterwards, so that later inserts do not collide. Computed and
+rowversion columns in the csv file are skipped.
+
+On a terminal, the progress (rows/s, bytes read and ETA) is shown on
+STDERR, otherwise it is logged every 10 seconds. --metrics-file writes the
+statistics of the load, including the time spent parsing the file and the
+time spent on the server, as JSON.
+
+With --dry-run, the whole file is parsed and validated against the table
+without sending any rows: the columns, the types, NULLs in NOT NULL columns
+and the lengths of strings are checked. The problems are printed to STDOUT
+and the exit code is 1 if there are any.`,
+	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		flags := cmd.Flags()
 		dsn, err := buildDSN(flags)
 		if err != nil {
 			log.Errorw("could not build DSN", zap.Error(err))
+			return err
 		}
 		nullstr, err := flags.GetString("nullstr")
 		if err != nil {
 			log.Errorw("could not parse nullstr flag", zap.Error(err))
+			return err
 		}
 		log.Infof("null string is »%s«", nullstr)
 		sep, err := flags.GetString("sep")
 		if err != nil {
 			log.Errorw("could not parse sep flag", zap.Error(err))
+			return err
 		}
 		sepRune, _ := utf8.DecodeRuneInString(sep)
 		log.Infof("sep is »%s«", sep)
 
-		var colTypes ColTypes
+		var colTypes mssqlload.ColTypes
 		if flags.Changed("types") {
-			v, err := flags.GetString("types")
+			v, _ := flags.GetString("types")
+			colTypes, err = mssqlload.LoadColTypes(v)
 			if err != nil {
-				log.Errorw("could not parse types flag: %w", err)
-			}
-			colTypes, err = LoadColTypes(v)
-			if err != nil {
-				log.Errorw("could not parse types file: %w", err)
+				log.Errorw("could not parse types file", zap.Error(err))
+				return err
 			}
 		}
 
-		tblname := args[0]
-		f := args[1]
-		log.Infof("loading csv file %s", f)
-		nrows, err := loadcsv(tblname, f, dsn, nullstr, sepRune, colTypes)
-		if err != nil {
+		mode, _ := flags.GetString("mode")
+		if mode, err = mssqlload.ParseMode(mode); err != nil {
 			return err
 		}
-		log.Infof("inserted %d rows", nrows)
-		log.Infof("loaded file successfully!")
-		return nil
-	},
-}
-
-type Header struct {
-	Colnames []string
-	Colopt   []bool
-	Ncols    int
-	Types    []string
-	Parsers  []func(string) (any, error)
-}
-
-func parseInt(v string) (any, error)   { return strconv.ParseInt(v, 10, 64) }
-func parseFloat(v string) (any, error) { return strconv.ParseFloat(v, 64) }
-
-func parseBool(v string) (any, error) {
-	// this should account for TRUE, true, T, t
-	if strings.HasPrefix(strings.ToLower(v), "t") {
-		return true, nil
-	}
-	// this should account for YES, yes, Y, y
-	if strings.HasPrefix(strings.ToLower(v), "y") {
-		return true, nil
-	}
-	// so, perhaps we have a number (0/1)
-	vParsed, err := strconv.ParseInt(v, 10, 64)
-	if err != nil {
-		return false, err
-	}
-	return vParsed > 0, nil
-}
-func parseString(v string) (any, error) { return v, nil }
-func parseHeader(header []string, colTypes ColTypes) Header {
-	ncols := len(header)
-
-	types := make([]string, ncols)
-	parsers := make([]func(string) (any, error), ncols)
-	colnames := make([]string, ncols)
-	colopt := make([]bool, ncols)
-	for colidx, col := range header {
-		res := strings.SplitN(col, "::", 2)
-		var colname, coltype string
-		if len(res) == 2 {
-			colname, coltype = res[0], res[1]
-		} else {
-			// no type info in column header, just assume string
-			colname = col
-			coltype = ""
-		}
-
-		if ct, found := colTypes.Find(colidx, colname); found {
-			coltype = ct
+		key, _ := flags.GetStringSlice("key")
+		deleteMissing, _ := flags.GetBool("delete-missing")
+		keepIdentity, _ := flags.GetBool("keep-identity")
+		if mode == mssqlload.ModeUpsert && len(key) == 0 {
+			return fmt.Errorf("--mode upsert needs --key")
 		}
-
-		colopt[colidx] = false
-		if len(coltype) > 0 && coltype[len(coltype)-1:] == "!" {
-			colopt[colidx] = true
-			coltype = coltype[:len(coltype)-1]
-		}
-
-		colnames[colidx] = colname
-		switch coltype {
-		case "int":
-			parsers[colidx] = parseInt
-			types[colidx] = "int"
-		case "float":
-			parsers[colidx] = parseFloat
-			types[colidx] = "float"
-		case "bool":
-			parsers[colidx] = parseBool
-			types[colidx] = "bool"
-		case "string":
-			parsers[colidx] = parseString
-			types[colidx] = "string"
-		default:
-			log.Infow("No column type specified, using string.",
-				"name", colname,
-				"idx", colidx,
-				"type", coltype,
-			)
-			parsers[colidx] = parseString
-			types[colidx] = "string"
+		if mode != mssqlload.ModeUpsert && (len(key) > 0 || deleteMissing) {
+			return fmt.Errorf("--key and --delete-missing need --mode upsert")
 		}
-	}
-
-	return Header{
-		Colopt:   colopt,
-		Colnames: colnames,
-		Parsers:  parsers,
-		Ncols:    ncols,
-		Types:    types,
-	}
-}
-
-func parseRow(header Header, row []string, nullstr string) []any {
-	if len(row) != header.Ncols {
-		log.Fatalw("could not read csv")
-	}
 
-	parsedRow := make([]any, header.Ncols)
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
 
-	for idx, v := range row {
-		if header.Colopt[idx] && v == nullstr {
-			parsedRow[idx] = nil
-			continue
-		}
-		res, err := header.Parsers[idx](v)
+		tblname := args[0]
+		f := args[1]
+		log.Infof("loading csv file %s", f)
+		fp, err := util.OpenFileorStdin(f, log)
 		if err != nil {
-			log.Fatalw("could not parse", zap.Error(err))
+			return err
 		}
-		parsedRow[idx] = res
+		defer fp.Close()
 
-	}
-	return parsedRow
-}
-func loadcsv(tblname string, f string, dsn *url.URL, nullstr string, sep rune, colTypes ColTypes) (int64, error) {
-	fp, err := util.OpenFileorStdin(f, log)
-	if err != nil {
-		return 0, fmt.Errorf("unable to read input file: %w", err)
-	}
-	defer fp.Close()
-
-	csvReader := csv.NewReader(fp)
-	csvReader.Comma = sep
-
-	rawHeader, err := csvReader.Read()
-	header := parseHeader(rawHeader, colTypes)
-	if err != nil {
-		return 0, fmt.Errorf("could not read csv: %w", err)
-	}
-	log.Info("columns")
-	log.Infof("%s   %-40s%-15s%s", "IDX", "NAME", "TYPE", "NULLABLE")
-	for idx, name := range header.Colnames {
-		n := ""
-		if header.Colopt[idx] {
-			n = "NULL"
+		con, err := db.Open(dsn)
+		if err != nil {
+			return err
 		}
-		log.Infof("[%3d] %-40s%-15s%s", idx, name, header.Types[idx], n)
-	}
-
-	con, err := db.Open(dsn)
-	if err != nil {
-		return 0, fmt.Errorf("could not connect to db: %w", err)
-	}
-
-	txn := con.MustBegin()
-	stmt, err := txn.Prepare(mssql.CopyIn(tblname, mssql.BulkOptions{}, header.Colnames...))
-	if err != nil {
-		return 0, err
-	}
-
-	for {
-		record, err := csvReader.Read()
-		if err == io.EOF {
-			break
+		defer con.Close()
+
+		opts := mssqlload.CSVOptions{
+			NullString:    nullstr,
+			Separator:     sepRune,
+			ColTypes:      colTypes,
+			Mode:          mode,
+			Key:           key,
+			DeleteMissing: deleteMissing,
+			KeepIdentity:  keepIdentity,
+			Log:           log,
+		}
+		if dryRun(cmd) {
+			return validateCSV(ctx, con.DB, tblname, fp, opts)
+		}
+
+		prog, r := newProgress(fp, log)
+		opts.Progress = prog.update
+		res, err := mssqlload.LoadCSV(ctx, con.DB, tblname, r, opts)
+		prog.done()
+		elapsed := time.Since(prog.start)
+		if metricsFile, _ := flags.GetString("metrics-file"); metricsFile != "" {
+			m := loadMetrics{
+				File: f, Table: tblname,
+				Rows: res.Rows, Inserted: res.Inserted, Updated: res.Updated, Deleted: res.Deleted,
+				Bytes:         prog.reader.n,
+				Seconds:       elapsed.Seconds(),
+				ParseSeconds:  res.ParseTime.Seconds(),
+				ServerSeconds: res.ServerTime.Seconds(),
+			}
+			if err != nil {
+				m.Rows = prog.rows
+				m.Error = err.Error()
+			}
+			m.RowsPerSecond = float64(m.Rows) / elapsed.Seconds()
+			if werr := writeMetrics(metricsFile, []loadMetrics{m}); werr != nil {
+				log.Errorw("could not write metrics file", zap.Error(werr))
+			}
 		}
 		if err != nil {
-			return 0, fmt.Errorf("error reading csv: %w", err)
+			return err
 		}
-		row := parseRow(header, record, nullstr)
-
-		_, err = stmt.Exec(row...)
-
-		if err != nil {
-			return 0, fmt.Errorf("could not exec sql: %w", err)
+		switch mode {
+		case mssqlload.ModeUpsert:
+			log.Infof("read %d rows: inserted %d, updated %d, deleted %d rows", res.Rows, res.Inserted, res.Updated, res.Deleted)
+		case mssqlload.ModeAppend:
+			log.Infof("inserted %d rows", res.Inserted)
+		default:
+			log.Infof("deleted %d rows, inserted %d rows", res.Deleted, res.Inserted)
 		}
-	}
+		log.Infof("loaded %d rows in %s (%.0f rows/s), parsing took %s, the server %s",
+			res.Rows, elapsed.Round(time.Millisecond), float64(res.Rows)/elapsed.Seconds(),
+			res.ParseTime.Round(time.Millisecond), res.ServerTime.Round(time.Millisecond))
+		log.Infof("loaded file successfully!")
+		return nil
+	},
+}
 
-	result, err := stmt.Exec()
+// validateCSV validates the csv file for --dry-run.
+func validateCSV(ctx context.Context, con *sql.DB, table string, r io.Reader, opts mssqlload.CSVOptions) error {
+	res, err := mssqlload.ValidateCSV(ctx, con, table, r, opts)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-
-	err = stmt.Close()
-	if err != nil {
-		log.Fatal(err)
+	for _, p := range res.Problems {
+		fmt.Println(p)
 	}
-
-	err = txn.Commit()
-	if err != nil {
-		log.Fatal(err)
+	if res.NProblems > len(res.Problems) {
+		fmt.Printf("... and %d more problems\n", res.NProblems-len(res.Problems))
 	}
-	rowCount, _ := result.RowsAffected()
-
-	return rowCount, nil
+	log.Infof("dry run: validated %d rows", res.Rows)
+	if res.NProblems > 0 {
+		return fmt.Errorf("found %d validation problems", res.NProblems)
+	}
+	return nil
 }
@@ -1,12 +1,31 @@
 package cmd
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
 	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 func init() {
+	loadsqlCmd.Flags().Bool("no-transaction", false, `Execute the statements without a surrounding
+transaction, e.g. for CREATE DATABASE`)
+	loadsqlCmd.Flags().String("validate", "", `Only validates the batches on the server: parse
+(SET PARSEONLY ON) or noexec (SET NOEXEC ON, also
+resolves objects and columns). "--validate" alone
+means noexec`)
+	loadsqlCmd.Flags().Lookup("validate").NoOptDefVal = mssqlload.ValidateCompile
+	loadsqlCmd.Flags().Bool("describe", false, `Prints the columns of the first result set of each
+batch (sp_describe_first_result_set), used with --validate`)
 	rootCmd.AddCommand(loadsqlCmd)
 }
 
@@ -17,22 +36,135 @@ var loadsqlCmd = &cobra.Command{
 
 You can supply a sql file as arg. All statements in this file will be parsed
 and executed separately. You can separate statements with a line containing
-only the keyword "GO".`,
+only the keyword "GO". All statements are executed in one transaction, unless
+--no-transaction is given. Files with statements that are not allowed in a
+transaction (CREATE/ALTER/DROP DATABASE, BACKUP, RESTORE, RECONFIGURE,
+full-text catalogs and indexes) are rejected before anything is executed,
+they need --no-transaction.
+
+With --validate, each batch is sent to the server with SET PARSEONLY ON or
+SET NOEXEC ON, which reports syntax and binding errors without executing
+anything. All errors are printed with the batch and the line in the file,
+the exit code is 1 if there are any. Objects created by the file do not
+exist during the validation, so validate against a db with the schema in
+place, e.g. a throwaway container, or use --validate=parse. Batches that
+set NOEXEC or PARSEONLY themselves are reported as errors and not sent.
+
+With --dry-run, the batches are printed with their line numbers instead,
+without connecting to the db.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dsn,err := buildDSN(cmd.Flags())
+		if dryRun(cmd) {
+			return printSQLBatches(args[0])
+		}
+		dsn, err := buildDSN(cmd.Flags())
 		if err != nil {
 			log.Errorw("could not build DSN", zap.Error(err))
 			return err
 		}
+		noTx, _ := cmd.Flags().GetBool("no-transaction")
+		validate, _ := cmd.Flags().GetString("validate")
+		describe, _ := cmd.Flags().GetBool("describe")
+		if validate != "" && validate != mssqlload.ValidateParse && validate != mssqlload.ValidateCompile {
+			return fmt.Errorf("invalid --validate %q, expected parse or noexec", validate)
+		}
+		if describe && validate == "" {
+			return fmt.Errorf("--describe needs --validate")
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
 
 		f := args[0]
 		log.Infof("loading sql file %s", f)
-		err = db.LoadSql(log, f, dsn)
+		fp, err := util.OpenFileorStdin(f, log)
 		if err != nil {
 			return err
 		}
+		defer fp.Close()
+
+		con, err := db.Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer con.Close()
+
+		if validate != "" {
+			return validateSQL(ctx, con.DB, f, fp, mssqlload.ValidateSQLOptions{
+				Mode:             validate,
+				Describe:         describe,
+				StatementTimeout: statementTimeout(cmd),
+				Log:              log,
+			})
+		}
+
+		res, err := mssqlload.LoadSQL(ctx, con.DB, fp, mssqlload.LoadSQLOptions{
+			NoTransaction:    noTx,
+			StatementTimeout: statementTimeout(cmd),
+			Log:              log,
+		})
+		if errors.Is(err, mssqlload.ErrNotInTransaction) {
+			return fmt.Errorf("%w, use --no-transaction", err)
+		}
+		if err != nil {
+			return err
+		}
+		log.Infof("executed %d batches", res.Batches)
 		log.Infof("loaded file successfully!")
 		return nil
 	},
 }
+
+// printSQLBatches prints the batches of sql file f for --dry-run.
+func printSQLBatches(f string) error {
+	fp, err := util.OpenFileorStdin(f, log)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	script, err := io.ReadAll(fp)
+	if err != nil {
+		return err
+	}
+	n, err := printBatches(os.Stdout, string(script))
+	if err != nil {
+		return err
+	}
+	log.Infof("dry run: %d batches in %s", n, f)
+	return nil
+}
+
+// validateSQL validates the sql file f for --validate.
+func validateSQL(ctx context.Context, con *sql.DB, f string, r io.Reader, opts mssqlload.ValidateSQLOptions) error {
+	res, err := mssqlload.ValidateSQL(ctx, con, r, opts)
+	if err != nil {
+		return err
+	}
+	for i, b := range res.Batches {
+		cols, ok := res.Columns[i]
+		if !ok {
+			continue
+		}
+		var desc []string
+		for _, c := range cols {
+			null := "NOT NULL"
+			if c.Nullable {
+				null = "NULL"
+			}
+			desc = append(desc, fmt.Sprintf("%s %s %s", c.Name, c.Type, null))
+		}
+		fmt.Printf("%s:%d: batch %d: %s\n", f, b.StartLine, i+1, strings.Join(desc, ", "))
+	}
+	for _, e := range res.Errors {
+		line := e.Line
+		if line == 0 {
+			line = e.StartLine
+		}
+		fmt.Printf("%s:%d: %v\n", f, line, e)
+	}
+	log.Infof("validated %d batches", len(res.Batches))
+	if len(res.Errors) > 0 {
+		return fmt.Errorf("found %d errors", len(res.Errors))
+	}
+	return nil
+}
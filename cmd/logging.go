@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jwbargsten/go-mssql-load/config"
+	"github.com/jwbargsten/go-mssql-load/util"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// quiet hides the progress display
+	quiet bool
+	// driverLog is the log bitmask of go-mssqldb, added to the DSN
+	driverLog string
+)
+
+// resolveLogSettings determines the log settings. The precedence is
+// flag > env var > config file > default.
+func resolveLogSettings(flags *pflag.FlagSet) (config.LogSettings, error) {
+	cfgFile, err := config.LoadFiles(configFiles(flags)...)
+	if err != nil {
+		return config.LogSettings{}, err
+	}
+	s, err := cfgFile.Log.WithEnv()
+	if err != nil {
+		return s, err
+	}
+	for name, dst := range map[string]*string{
+		"log-level": &s.Level, "log-format": &s.Format, "log-file": &s.File, "driver-log": &s.Driver,
+	} {
+		if flags.Changed(name) {
+			*dst, _ = flags.GetString(name)
+		}
+	}
+	if flags.Changed("quiet") {
+		q, _ := flags.GetBool("quiet")
+		s.Quiet = &q
+	}
+	if s.Driver != "" {
+		if _, err := strconv.ParseUint(s.Driver, 10, 8); err != nil {
+			return s, fmt.Errorf("invalid driver log bitmask %q", s.Driver)
+		}
+	}
+	return s, nil
+}
+
+// setupLogging replaces the default logger according to the log settings
+// and routes the driver log through it.
+func setupLogging(flags *pflag.FlagSet) error {
+	s, err := resolveLogSettings(flags)
+	if err != nil {
+		return err
+	}
+	l, err := util.BuildLogger(util.LogOptions{Level: s.Level, Format: s.Format, File: s.File, Quiet: s.IsQuiet()})
+	if err != nil {
+		return err
+	}
+	log = l
+	quiet = s.IsQuiet()
+	driverLog = s.Driver
+	if driverLog != "" && driverLog != "0" {
+		mssql.SetLogger(util.NewDriverLogger(log))
+	}
+	return nil
+}
@@ -2,8 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/jwbargsten/go-mssql-load/config"
+	"github.com/jwbargsten/go-mssql-load/db"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"io"
+	"os"
+	"text/tabwriter"
 )
 
 func init() {
@@ -14,9 +19,19 @@ func init() {
 var printDsnCmd = &cobra.Command{
 	Use:   "printdsn",
 	Short: "Print the DSN",
-	Long:  `Print the DSN.`,
+	Long: `Print the DSN.
+
+The DSN is printed to STDOUT. The resolved value of every connection
+setting, annotated with its source (flag, env var, profile or default),
+is printed to STDERR.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dsn, err := buildDSN(cmd.Flags())
+		cfg, err := resolveConfig(cmd.Flags())
+		if err != nil {
+			log.Errorw("could not build DSN", zap.Error(err))
+			return err
+		}
+		printSources(os.Stderr, cfg)
+		dsn, err := db.DSNFromConfig(cfg)
 		if err != nil {
 			log.Errorw("could not build DSN", zap.Error(err))
 			return err
@@ -34,3 +49,23 @@ var printDsnCmd = &cobra.Command{
 		return nil
 	},
 }
+
+func printSources(w io.Writer, cfg config.Config) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range config.Fields {
+		value := cfg.Get(name)
+		switch {
+		case value == "":
+		case name == "pass":
+			value = "xxxxx"
+		case name == "dsn":
+			if u, err := db.ParseDSN(value); err == nil {
+				value = u.Redacted()
+			} else {
+				value = "xxxxx"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\n", name, value, cfg.Sources[name])
+	}
+	tw.Flush()
+}
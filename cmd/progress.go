@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/chzyer/readline"
+	"go.uber.org/zap"
+)
+
+// countingReader counts the bytes read, to report the progress through a
+// file.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+const (
+	ttyProgressInterval = 200 * time.Millisecond
+	logProgressInterval = 10 * time.Second
+)
+
+// progress reports the rows and bytes loaded. On a terminal it redraws a
+// status line on stderr, otherwise it logs every logProgressInterval.
+type progress struct {
+	log    *zap.SugaredLogger
+	out    io.Writer
+	tty    bool
+	reader *countingReader
+	// size is the file size, 0 if unknown (e.g. stdin)
+	size  int64
+	rows  int64
+	start time.Time
+	last  time.Time
+}
+
+func newProgress(f *os.File, log *zap.SugaredLogger) (*progress, io.Reader) {
+	p := &progress{
+		log:    log,
+		out:    os.Stderr,
+		tty:    !quiet && readline.IsTerminal(int(os.Stderr.Fd())),
+		reader: &countingReader{r: f},
+		start:  time.Now(),
+	}
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+		p.size = fi.Size()
+	}
+	p.last = p.start
+	return p, p.reader
+}
+
+func (p *progress) update(rows int64) {
+	p.rows = rows
+	now := time.Now()
+	interval := logProgressInterval
+	if p.tty {
+		interval = ttyProgressInterval
+	}
+	if now.Sub(p.last) < interval {
+		return
+	}
+	p.last = now
+	elapsed := now.Sub(p.start)
+	rate := float64(rows) / elapsed.Seconds()
+	eta := p.eta(elapsed)
+
+	if !p.tty {
+		kv := []any{"rows", rows, "rows_per_sec", int64(rate), "bytes", p.reader.n}
+		if p.size > 0 {
+			kv = append(kv, "size", p.size, "eta", eta.String())
+		}
+		p.log.Infow("progress", kv...)
+		return
+	}
+	line := fmt.Sprintf("%d rows  %.0f rows/s  %s", rows, rate, formatBytes(p.reader.n))
+	if p.size > 0 {
+		line += fmt.Sprintf(" / %s (%.0f%%)  ETA %s", formatBytes(p.size), 100*float64(p.reader.n)/float64(p.size), eta)
+	}
+	fmt.Fprintf(p.out, "\r\033[K%s", line)
+}
+
+// eta extrapolates the remaining time from the bytes read.
+func (p *progress) eta(elapsed time.Duration) time.Duration {
+	if p.size == 0 || p.reader.n == 0 {
+		return 0
+	}
+	remaining := float64(p.size-p.reader.n) / float64(p.reader.n)
+	return time.Duration(remaining * float64(elapsed)).Round(time.Second)
+}
+
+// done clears the status line.
+func (p *progress) done() {
+	if p.tty && p.last != p.start {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// loadMetrics are the statistics of a load, written to --metrics-file.
+type loadMetrics struct {
+	File          string  `json:"file"`
+	Table         string  `json:"table"`
+	Rows          int64   `json:"rows"`
+	Inserted      int64   `json:"inserted"`
+	Updated       int64   `json:"updated"`
+	Deleted       int64   `json:"deleted"`
+	Bytes         int64   `json:"bytes"`
+	Seconds       float64 `json:"seconds"`
+	ParseSeconds  float64 `json:"parse_seconds"`
+	ServerSeconds float64 `json:"server_seconds"`
+	RowsPerSecond float64 `json:"rows_per_second"`
+	Error         string  `json:"error,omitempty"`
+}
+
+func writeMetrics(f string, loads []loadMetrics) error {
+	out, err := json.MarshalIndent(map[string]any{"loads": loads}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(f, append(out, '\n'))
+}
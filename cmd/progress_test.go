@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:             "512 B",
+		2048:            "2.0 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 30:         "3.0 GiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("%d: got %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{
+		log:    zap.NewNop().Sugar(),
+		out:    &out,
+		tty:    true,
+		reader: &countingReader{r: strings.NewReader(strings.Repeat("x", 100))},
+		size:   400,
+		start:  time.Now().Add(-10 * time.Second),
+	}
+	p.last = p.start
+	if _, err := io.ReadAll(p.reader); err != nil {
+		t.Fatal(err)
+	}
+	if p.reader.n != 100 {
+		t.Fatalf("counted %d bytes", p.reader.n)
+	}
+	if eta := p.eta(10 * time.Second); eta != 30*time.Second {
+		t.Errorf("got ETA %s", eta)
+	}
+
+	p.update(1000)
+	if got := out.String(); !strings.Contains(got, "1000 rows") || !strings.Contains(got, "(25%)  ETA 30s") {
+		t.Errorf("got %q", got)
+	}
+	// within the interval, nothing is drawn
+	out.Reset()
+	p.update(2000)
+	if out.Len() != 0 || p.rows != 2000 {
+		t.Errorf("got %q, %d rows", out.String(), p.rows)
+	}
+	p.done()
+	if out.String() != "\r\033[K" {
+		t.Errorf("got %q", out.String())
+	}
+}
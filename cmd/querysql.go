@@ -1,12 +1,27 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"io"
+	"os"
 )
 
 func init() {
+	querySqlCmd.Flags().String("format", "json", "Output format: json (newline delimited), table or csv")
+	querySqlCmd.Flags().StringSlice("expect", nil, `Compares the results with the expected rows
+instead of printing them. Either one file for all
+batches (separated by "---") or one file per batch`)
+	querySqlCmd.Flags().Bool("unordered", false, "Ignores the order of the rows, used with --expect")
+	querySqlCmd.Flags().StringSlice("ignore-columns", nil, "Columns to ignore, used with --expect")
+	querySqlCmd.Flags().Float64("tolerance", 0, `Maximum absolute difference of numbers that are
+considered equal, used with --expect`)
+	querySqlCmd.Flags().Bool("update", false, "Rewrites the --expect files with the actual results")
 	rootCmd.AddCommand(querySqlCmd)
 }
 
@@ -17,22 +32,149 @@ var querySqlCmd = &cobra.Command{
 
 You can supply a sql file as arg. All statements in this file will be parsed
 and executed separately. You can separate statements with a line containing
-only the keyword "GO".`,
+only the keyword "GO". The results of the statements are separated by "---".
+
+With --expect, the results are compared with golden files in the same
+(newline delimited JSON) format. Differences are printed row by row, "-"
+marks expected and "+" actual rows, and the exit code is 1. Golden files
+can be created or updated with --update.
+
+With --dry-run, the batches are printed with their line numbers instead,
+without connecting to the db.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dsn,err := buildDSN(cmd.Flags())
+		flags := cmd.Flags()
+		if update, _ := flags.GetBool("update"); update && !flags.Changed("expect") {
+			return fmt.Errorf("--update needs --expect")
+		}
+		if dryRun(cmd) {
+			return printSQLBatches(args[0])
+		}
+		dsn, err := buildDSN(flags)
 		if err != nil {
 			log.Errorw("could not build DSN", zap.Error(err))
 			return err
 		}
+		v, _ := flags.GetString("format")
+		format, err := mssqlload.ParseFormat(v)
+		if err != nil {
+			return err
+		}
+		expect, _ := flags.GetStringSlice("expect")
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
 
 		f := args[0]
 		log.Infof("running queries from sql file %s", f)
-		err = db.QuerySql(log, f, dsn)
+		fp, err := util.OpenFileorStdin(f, log)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		script, err := io.ReadAll(fp)
+		if err != nil {
+			return err
+		}
+
+		con, err := db.Open(dsn)
+		if err != nil {
+			return err
+		}
+		defer con.Close()
+
+		var w io.Writer = os.Stdout
+		if len(expect) > 0 {
+			w = nil
+		}
+		results, err := mssqlload.Query(ctx, con.DB, bytes.NewReader(script), w, mssqlload.QueryOptions{
+			Format:           format,
+			StatementTimeout: statementTimeout(cmd),
+		})
 		if err != nil {
 			return err
 		}
 		log.Infof("all queries successfully executed!")
-		return nil
+		if len(expect) == 0 {
+			return nil
+		}
+
+		actual, err := mssqlload.ResultRows(results, len(mssqlload.SplitBatches(string(script))))
+		if err != nil {
+			return err
+		}
+		if update, _ := flags.GetBool("update"); update {
+			return writeExpected(expect, actual)
+		}
+		expected, err := readExpected(expect)
+		if err != nil {
+			return err
+		}
+		if len(expected) != len(actual) {
+			return fmt.Errorf("expected results of %d batches, got %d", len(expected), len(actual))
+		}
+
+		var opts mssqlload.CompareOptions
+		opts.Unordered, _ = flags.GetBool("unordered")
+		opts.IgnoreColumns, _ = flags.GetStringSlice("ignore-columns")
+		opts.Tolerance, _ = flags.GetFloat64("tolerance")
+		var diffs []mssqlload.RowDiff
+		for i := range expected {
+			diffs = append(diffs, mssqlload.Compare(i, expected[i], actual[i], opts)...)
+		}
+		if len(diffs) == 0 {
+			log.Infof("results match %v", expect)
+			return nil
+		}
+		if err := mssqlload.FormatDiffs(os.Stdout, diffs, opts.Unordered); err != nil {
+			return err
+		}
+		return fmt.Errorf("results differ from %v in %d rows", expect, len(diffs))
 	},
 }
+
+// readExpected reads the golden files, either one for all batches or one
+// per batch.
+func readExpected(files []string) ([][]mssqlload.Row, error) {
+	var res [][]mssqlload.Row
+	for _, f := range files {
+		fp, err := os.Open(f)
+		if err != nil {
+			return nil, err
+		}
+		batches, err := mssqlload.ParseNDJSON(fp)
+		fp.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", f, err)
+		}
+		if len(files) > 1 && len(batches) > 1 {
+			return nil, fmt.Errorf("%s contains more than one batch, expected one file per batch", f)
+		}
+		res = append(res, batches...)
+	}
+	return res, nil
+}
+
+func writeExpected(files []string, batches [][]mssqlload.Row) error {
+	if len(files) == 1 {
+		return writeNDJSONFile(files[0], batches)
+	}
+	if len(files) != len(batches) {
+		return fmt.Errorf("got %d files for %d batches", len(files), len(batches))
+	}
+	for i, f := range files {
+		if err := writeNDJSONFile(f, batches[i:i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeNDJSONFile(f string, batches [][]mssqlload.Row) error {
+	var buf bytes.Buffer
+	if err := mssqlload.WriteNDJSON(&buf, batches); err != nil {
+		return err
+	}
+	log.Infof("writing %s", f)
+	return os.WriteFile(f, buf.Bytes(), 0o644)
+}
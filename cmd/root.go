@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/jwbargsten/go-mssql-load/config"
+	"github.com/jwbargsten/go-mssql-load/db"
 	"github.com/jwbargsten/go-mssql-load/util"
 	"github.com/spf13/pflag"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -15,7 +20,7 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "go-mssql-load",
 	Short: "Utility functions for loading data into mssql server",
-	Long:  `Utility functions for loading data into mssql server
+	Long: `Utility functions for loading data into mssql server
 
 The connection parameters can be supplied via CLI flags
 and via environment variables. If a flag has a corresponding
@@ -32,13 +37,53 @@ by go-mssql-load.
 
 NOTE:
   If the DSN is specified (as param or env var), it takes precedence
-	over all other flags/vars.
+	over all other flags/vars. A DSN of a profile cannot be combined
+	with other flags or env vars.
+
+Connection parameters can also be stored as named profiles in a config
+file, see the --profile and --config flags:
+
+  default_profile: local
+  profiles:
+    local:
+      user: sa
+      pass: Passw0rd
+    ci:
+      host: mssql
+      port: 1433
+      name: pokedb
+
+The precedence is: CLI flag > env var > profile > default.
 
 All supported parameters can be found on the official go-mssqldb lib:
 https://github.com/microsoft/go-mssqldb#connection-parameters-and-dsn
 
 File arguments can also take "-" as file name for reading the file
 contents from STDIN.
+
+Logging is configured with the --log-* flags, the corresponding env vars
+or the log section of the config file:
+
+  log:
+    level: debug
+    format: logfmt
+
+With --dry-run, loadsql and querysql print the batches they would
+execute, loadcsv validates the csv file against the table and generate
+generates the rows without inserting them. The exit code is 1 if a
+problem is found.
+
+All commands can be cancelled with Ctrl-C (SIGINT) or SIGTERM. Open
+transactions are rolled back. --timeout limits the run time of the whole
+command, --statement-timeout the run time of each statement (batch).
+
+Exit codes:
+  1    general error
+  3    timeout (waiting for the db, --timeout or --statement-timeout)
+  4    authentication failure
+  5    network failure
+  130  cancelled by SIGINT (Ctrl-C)
+  143  cancelled by SIGTERM
 `,
 }
 
@@ -48,110 +93,227 @@ func init() {
 which the server is running. {MSSQL_HOST}`)
 	rootCmd.PersistentFlags().IntP("port", "p", 1433, `Specifies the TCP port on which the server is
 listening for connections. {MSSQL_PORT}`)
+	rootCmd.PersistentFlags().String("ca-file", "", `PEM file with the CA certificate(s) used to
+verify the server certificate. {MSSQL_CA_FILE}`)
+	rootCmd.PersistentFlags().String("hostname-in-certificate", "", `Overrides the host name expected in the
+server certificate. {MSSQL_HOSTNAME_IN_CERTIFICATE}`)
+	rootCmd.PersistentFlags().String("tls-min", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3. {MSSQL_TLS_MIN}")
+	rootCmd.PersistentFlags().String("client-cert", "", "PEM file with the client certificate. {MSSQL_CLIENT_CERT}")
+	rootCmd.PersistentFlags().String("client-key", "", "PEM file with the client key. {MSSQL_CLIENT_KEY}")
+	rootCmd.PersistentFlags().String("instance", "", `Specifies the named instance. The port is
+resolved via the SQL Server Browser, unless
+--port is given. "--host SERVER\INSTANCE" works
+as well. {MSSQL_INSTANCE}`)
+	rootCmd.PersistentFlags().String("failover-partner", "", "Specifies the failover partner host. {MSSQL_FAILOVER_PARTNER}")
+	rootCmd.PersistentFlags().String("app-name", "", "Specifies the application name. {MSSQL_APP_NAME}")
+	rootCmd.PersistentFlags().Int("connection-timeout", 0, `Specifies the connection timeout in seconds,
+0 means no timeout. {MSSQL_CONNECTION_TIMEOUT}`)
+	rootCmd.PersistentFlags().Int("dial-timeout", 0, `Specifies the dial timeout in seconds, the
+driver default is 15. {MSSQL_DIAL_TIMEOUT}`)
+	rootCmd.PersistentFlags().Int("packet-size", 0, `Specifies the TDS packet size in bytes, the
+driver default is 4096. {MSSQL_PACKET_SIZE}`)
+	rootCmd.PersistentFlags().String("application-intent", "", `Specifies the application intent, ReadOnly
+routes to a readable secondary. {MSSQL_APPLICATION_INTENT}`)
+	rootCmd.PersistentFlags().Bool("multi-subnet-failover", false, `Connects to all IPs of the host in
+parallel. {MSSQL_MULTI_SUBNET_FAILOVER}`)
 	rootCmd.PersistentFlags().StringP("user", "U", "", "Specifies the user name. {MSSQL_USER}")
-	rootCmd.PersistentFlags().StringP("pass", "P", "", "Specifies the password. {MSSQL_PASS}")
-	rootCmd.PersistentFlags().Bool("encrypt", false, "Corresponds to the encrypt parameter of go-mssqldb. {MSSQL_ENCRYPT}")
+	rootCmd.PersistentFlags().StringP("pass", "P", "", `Specifies the password. Prefer one of the
+options below, the password leaks into the
+shell history and the process list. {MSSQL_PASS}`)
+	rootCmd.PersistentFlags().String("pass-file", "", `Reads the password from a file, e.g. a
+Docker/K8s secret. {MSSQL_PASS_FILE}`)
+	rootCmd.PersistentFlags().Bool("pass-stdin", false, `Reads the password from the first line of
+STDIN. Cannot be combined with "-" as file.`)
+	rootCmd.PersistentFlags().String("pass-command", "", `Runs a shell command and uses its output as
+password, e.g. 'pass show db/ci'. {MSSQL_PASS_COMMAND}`)
+	rootCmd.PersistentFlags().String("encrypt", "false", `Corresponds to the encrypt parameter of
+go-mssqldb: true, false, strict (TDS 8.0) or
+disable. "--encrypt" alone means true, other
+values need "=", e.g. --encrypt=strict. {MSSQL_ENCRYPT}`)
+	rootCmd.PersistentFlags().Lookup("encrypt").NoOptDefVal = "true"
 	rootCmd.PersistentFlags().Bool("trust-server-cert", true, `Corresponds to (and behaves like!) the
 TrustServerCertificate parameter of go-mssqldb.
 The default is determined by encrypt: if encrypt=true, trust=false;
 if encrypt=false, trust=true. {MSSQL_TRUST_SERVER_CERT}`)
-	rootCmd.PersistentFlags().String("dsn",  "", `Specifies the full dsn. If specified, takes
-precedence over all other parameters. {MSSQL_DSN}`)
+	rootCmd.PersistentFlags().String("dsn", "", `Specifies the full dsn. If specified, takes
+precedence over all other parameters. Besides the
+URL form, the ADO (Server=...;Database=...) and
+ODBC (odbc:server=...) forms are accepted. {MSSQL_DSN}`)
+	rootCmd.PersistentFlags().String("auth", "sql", `Specifies the authentication mode: sql, azure-sp,
+azure-msi, azure-cli, token or krb5. {MSSQL_AUTH}`)
+	rootCmd.PersistentFlags().String("token-file", "", `File with a pre-fetched access token, used with
+--auth token. The file is re-read for every new
+connection. {MSSQL_TOKEN_FILE}`)
+	rootCmd.PersistentFlags().String("tenant-id", "", `Entra ID (Azure AD) tenant, used with
+--auth azure-sp. {MSSQL_TENANT_ID}`)
+	rootCmd.PersistentFlags().String("krb5-config", "", "krb5.conf file, used with --auth krb5. {MSSQL_KRB5_CONFIG}")
+	rootCmd.PersistentFlags().String("krb5-keytab", "", "Keytab file, used with --auth krb5. {MSSQL_KRB5_KEYTAB}")
+	rootCmd.PersistentFlags().String("krb5-realm", "", "Kerberos realm, used with --auth krb5. {MSSQL_KRB5_REALM}")
+	rootCmd.PersistentFlags().String("profile", "", `Selects a connection profile from the config
+file. {MSSQL_PROFILE}`)
+	rootCmd.PersistentFlags().String("config", "", `Specifies the config file. By default
+.go-mssql-load.yaml is searched in the current
+directory and its parents, in addition to
+~/.config/go-mssql-load/config.yaml. {MSSQL_CONFIG}`)
+	rootCmd.PersistentFlags().Duration("timeout", 0, `Limits the run time of the command, e.g. 5m.
+0 means no limit. For "check --wait" the
+default is 90s.`)
+	rootCmd.PersistentFlags().Duration("statement-timeout", 0, `Limits the run time of each statement (batch),
+0 means no limit.`)
+	rootCmd.PersistentFlags().Bool("dry-run", false, `Parses and validates the input without changing
+the db, see the help of the commands.`)
+	rootCmd.PersistentFlags().String("log-level", "info", "Log level: debug, info, warn or error. {MSSQL_LOG_LEVEL}")
+	rootCmd.PersistentFlags().String("log-format", "console", "Log format: console, json or logfmt. {MSSQL_LOG_FORMAT}")
+	rootCmd.PersistentFlags().String("log-file", "", "Appends the log to this file instead of STDERR. {MSSQL_LOG_FILE}")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Only logs errors and hides progress. {MSSQL_QUIET}")
+	rootCmd.PersistentFlags().String("driver-log", "", `Enables go-mssqldb logging at debug level, as
+bitmask: 1 errors, 2 messages, 4 rows affected,
+8 SQL, 16 parameters, 32 transactions, e.g. 63
+for everything. {MSSQL_DRIVER_LOG}`)
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if passStdin, _ := cmd.Flags().GetBool("pass-stdin"); passStdin {
+			for _, arg := range args {
+				if arg == "-" {
+					return errors.New(`--pass-stdin cannot be combined with "-" as file, both read STDIN`)
+				}
+			}
+		}
+		return setupLogging(cmd.Flags())
+	}
 	rootCmd.SilenceUsage = true
-}
-
-func buildConnString(cfg config.Config) *url.URL {
-	q := make(url.Values)
-	q.Add("encrypt", cfg.WithEncryption)
-	q.Add("database", cfg.Name)
-	q.Add("TrustServerCertificate", cfg.WithTrustServerCert)
-	//query.Add("log", "63") --> to enable verbose logging
-	dbUrl := url.URL{
-		Scheme:   "sqlserver",
-		User:     url.UserPassword(cfg.User, cfg.Pass),
-		Host:     fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		RawQuery: q.Encode(),
-	}
-	return &dbUrl
+	// errors are printed by Execute, with secrets redacted
+	rootCmd.SilenceErrors = true
 }
 
 var log = util.NewLogger()
 
+// Exit codes, see exitCode.
+const (
+	exitError   = 1
+	exitTimeout = 3
+	exitAuth    = 4
+	exitNetwork = 5
+	// 128 + SIGINT and 128 + SIGTERM, as the shell reports them
+	exitCanceled   = 130
+	exitTerminated = 143
+)
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		if s, _ := receivedSignal.Load().(os.Signal); s == syscall.SIGTERM {
+			return exitTerminated
+		}
+		return exitCanceled
+	case errors.Is(err, db.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.Is(err, db.ErrAuth):
+		return exitAuth
+	case errors.Is(err, db.ErrNetwork):
+		return exitNetwork
+	}
+	return exitError
+}
+
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", util.Redact(err.Error()))
+		os.Exit(exitCode(err))
 	}
 }
 
-func buildDSN(flags *pflag.FlagSet) (*url.URL, error) {
-	cfg := config.New()
-	if flags.Changed("name") {
-		v, err := flags.GetString("name")
-		if err != nil {
-			return nil, fmt.Errorf("could not parse name flag: %w", err)
-		}
-		cfg.Name = v
+// resolveConfig determines the connection config. The precedence is
+// flag > env var > profile > default.
+func resolveConfig(flags *pflag.FlagSet) (config.Config, error) {
+	files := configFiles(flags)
+	profileName, _ := flags.GetString("profile")
+	cfg, err := config.Load(profileName, files...)
+	if err != nil {
+		return config.Config{}, err
 	}
-	if flags.Changed("host") {
-		v, err := flags.GetString("host")
-		if err != nil {
-			return nil, fmt.Errorf("could not parse host flag: %w", err)
-		}
-		cfg.Host = v
+	if cfg.Profile != "" {
+		log.Debugw("using profile", "profile", cfg.Profile, "files", files)
 	}
-	if flags.Changed("port") {
-		v, _ := flags.GetInt("port")
-		cfg.Port = strconv.Itoa(v)
+
+	for _, name := range []string{"name", "host", "ca-file", "hostname-in-certificate", "tls-min", "client-cert", "client-key", "instance", "failover-partner", "app-name", "application-intent", "user", "pass", "pass-file", "pass-command", "auth", "token-file", "tenant-id", "krb5-config", "krb5-keytab", "krb5-realm", "dsn"} {
+		if flags.Changed(name) {
+			v, err := flags.GetString(name)
+			if err != nil {
+				return config.Config{}, fmt.Errorf("could not parse %s flag: %w", name, err)
+			}
+			cfg.Set(name, v, "flag --"+name)
+		}
 	}
-	if flags.Changed("user") {
-		v, _ := flags.GetString("user")
-		cfg.User = v
+	for _, name := range []string{"port", "connection-timeout", "dial-timeout", "packet-size"} {
+		if flags.Changed(name) {
+			v, _ := flags.GetInt(name)
+			cfg.Set(name, strconv.Itoa(v), "flag --"+name)
+		}
 	}
-	if flags.Changed("pass") {
-		v, _ := flags.GetString("pass")
-		cfg.Pass = v
+	if flags.Changed("multi-subnet-failover") {
+		v, _ := flags.GetBool("multi-subnet-failover")
+		cfg.Set("multi-subnet-failover", strconv.FormatBool(v), "flag --multi-subnet-failover")
 	}
 	if flags.Changed("encrypt") {
-		v, _ := flags.GetBool("encrypt")
-		if v {
-			cfg.WithEncryption = "true"
-			cfg.WithTrustServerCert = "false"
-		} else {
-			cfg.WithEncryption = "false"
-			cfg.WithTrustServerCert = "true"
+		v, _ := flags.GetString("encrypt")
+		v = strings.ToLower(v)
+		var trust string
+		switch v {
+		case "true", "strict":
+			trust = "false"
+		case "false", "disable":
+			trust = "true"
+		default:
+			return config.Config{}, fmt.Errorf("invalid encrypt flag %q, expected true, false, strict or disable", v)
 		}
+		cfg.Set("encrypt", v, "flag --encrypt")
+		cfg.Set("trust-server-cert", trust, "flag --encrypt")
 	}
 	if flags.Changed("trust-server-cert") {
 		v, _ := flags.GetBool("trust-server-cert")
-		if v {
-			cfg.WithTrustServerCert = "true"
-		} else {
-			cfg.WithTrustServerCert = "false"
-		}
+		cfg.Set("trust-server-cert", strconv.FormatBool(v), "flag --trust-server-cert")
 	}
-
-	var dsn *url.URL
-	if flags.Changed("dsn") {
-		var err error
-		v, _ := flags.GetString("dsn")
-		dsn, err = url.Parse(v)
+	if v, _ := flags.GetBool("pass-stdin"); v {
+		pass, err := config.ReadPassword(os.Stdin)
 		if err != nil {
-			return nil, fmt.Errorf("could not parse dsn flag: %w", err)
-		}
-	} else {
-		v, ok := os.LookupEnv("MSSQL_DSN")
-		if ok {
-			var err error
-			dsn, err = url.Parse(v)
-			if err != nil {
-				return nil, fmt.Errorf("could not parse dsn env var: %w", err)
-			}
+			return config.Config{}, fmt.Errorf("could not read password from STDIN: %w", err)
 		}
+		cfg.Set("pass", pass, "flag --pass-stdin")
 	}
-	if dsn == nil {
-		dsn = buildConnString(cfg)
+	if err := cfg.ResolvePassword(); err != nil {
+		return config.Config{}, err
 	}
+	util.AddSecret(cfg.Pass)
+	if err := cfg.Validate(); err != nil {
+		return config.Config{}, err
+	}
+	return cfg, nil
+}
+
+// configFiles returns the config files given by --config or {MSSQL_CONFIG},
+// or else the ones found for the current directory.
+func configFiles(flags *pflag.FlagSet) []string {
+	if flags.Changed("config") {
+		v, _ := flags.GetString("config")
+		return []string{v}
+	}
+	return config.DefaultFiles()
+}
 
+func buildDSN(flags *pflag.FlagSet) (*url.URL, error) {
+	cfg, err := resolveConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := db.DSNFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if driverLog != "" {
+		q := dsn.Query()
+		q.Set("log", driverLog)
+		dsn.RawQuery = q.Encode()
+	}
 	return dsn, nil
 }
@@ -0,0 +1,426 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/jmoiron/sqlx"
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func init() {
+	shellCmd.Flags().String("format", "table", "Output format of result sets: table, json or csv")
+	shellCmd.Flags().String("history", defaultHistoryFile(), "History file, empty to disable history")
+	rootCmd.AddCommand(shellCmd)
+}
+
+const shellHelp = `Statements can span multiple lines. They are executed as soon as a
+line ends with ";" or a line contains only the keyword "GO". A ";" inside
+strings, comments or BEGIN ... END blocks does not end a statement.
+CREATE/ALTER PROCEDURE, FUNCTION and TRIGGER are only executed on "GO".
+
+Meta-commands:
+  \d [table]      list tables or describe the columns of a table
+  \dn             list schemas
+  \timing         toggle printing of execution times
+  \format <fmt>   set the output format: table, json or csv
+  \c              clear the statement buffer
+  \?              show this help
+  \q              quit`
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Interactive SQL shell",
+	Long: `Interactive SQL shell
+
+Opens a REPL on the configured connection. All statements run on the same
+session, so transactions and session settings persist between statements.
+Ctrl-C cancels the running statement, --statement-timeout applies to each
+statement.
+
+` + shellHelp,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		dsn, err := buildDSN(flags)
+		if err != nil {
+			log.Errorw("could not build DSN", zap.Error(err))
+			return err
+		}
+		v, _ := flags.GetString("format")
+		format, err := mssqlload.ParseFormat(v)
+		if err != nil {
+			return err
+		}
+		history, _ := flags.GetString("history")
+
+		ctx := cmd.Context()
+		con, err := db.Open(dsn)
+		if err != nil {
+			log.Errorw("could not connect to db", zap.Error(err))
+			return err
+		}
+		defer con.Close()
+		// a dedicated connection keeps the session (and open transactions) alive
+		// between statements
+		conn, err := con.Connx(ctx)
+		if err != nil {
+			log.Errorw("could not connect to db", zap.Error(err))
+			return err
+		}
+		defer conn.Close()
+
+		rl, err := readline.NewEx(&readline.Config{
+			HistoryFile:     history,
+			InterruptPrompt: "^C",
+			EOFPrompt:       `\q`,
+		})
+		if err != nil {
+			return err
+		}
+		defer rl.Close()
+
+		sh := &shell{conn: conn, out: rl.Stdout(), format: format, stmtTimeout: statementTimeout(cmd)}
+		return sh.run(ctx, rl)
+	},
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".go-mssql-load_history")
+}
+
+type shell struct {
+	conn        *sqlx.Conn
+	out         io.Writer
+	format      mssqlload.Format
+	timing      bool
+	database    string
+	tranCnt     int
+	stmtTimeout time.Duration
+}
+
+// stmtBuffer collects input lines until a statement is complete.
+type stmtBuffer struct {
+	lines []string
+
+	// scanner state of the lines so far
+	quote        byte // ', " or ] while in a string or quoted identifier
+	comment      int  // nesting depth of /* */ comments
+	depth        int  // nesting depth of BEGIN ... END and CASE ... END
+	pendingBegin bool // BEGIN seen, but not the word after it
+	words        []string
+	module       bool // CREATE PROCEDURE, FUNCTION or TRIGGER
+}
+
+// add appends a line to the buffer. If the line terminates the statement
+// (a line containing only "GO" or a trailing ";" outside of strings,
+// comments and BEGIN ... END blocks), the complete statement is returned and
+// the buffer is reset. The body of a procedure, function or trigger can
+// contain any number of ";", so these are only terminated by "GO".
+func (b *stmtBuffer) add(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if strings.EqualFold(trimmed, "GO") {
+		return b.flush(), true
+	}
+	b.lines = append(b.lines, line)
+	if b.scan(line) && b.depth == 0 && !b.pendingBegin && !b.module {
+		return b.flush(), true
+	}
+	return "", false
+}
+
+// scan updates the scanner state with line and reports whether the line
+// ends with a ";" outside of strings and comments.
+func (b *stmtBuffer) scan(line string) bool {
+	terminated := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		var next byte
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+		switch {
+		case b.comment > 0:
+			if c == '*' && next == '/' {
+				b.comment--
+				i++
+			} else if c == '/' && next == '*' {
+				b.comment++
+				i++
+			}
+		case b.quote != 0:
+			if c == b.quote {
+				if next == b.quote {
+					// escaped quote
+					i++
+				} else {
+					b.quote = 0
+				}
+			}
+			terminated = false
+		case c == '-' && next == '-':
+			return terminated
+		case c == '/' && next == '*':
+			b.comment++
+			i++
+		case c == '\'' || c == '"':
+			b.quote = c
+			terminated = false
+		case c == '[':
+			b.quote = ']'
+			terminated = false
+		case c == ';':
+			if b.pendingBegin {
+				b.pendingBegin = false
+				b.depth++
+			}
+			terminated = true
+		case isWordChar(c):
+			j := i
+			for j < len(line) && isWordChar(line[j]) {
+				j++
+			}
+			b.word(strings.ToUpper(line[i:j]))
+			i = j - 1
+			terminated = false
+		case c != ' ' && c != '\t' && c != '\r':
+			terminated = false
+		}
+	}
+	return terminated
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '@' || c == '#' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// word updates the block depth and detects module definitions.
+func (b *stmtBuffer) word(w string) {
+	if len(b.words) < 4 {
+		b.words = append(b.words, w)
+		b.module = b.module || isModuleStart(b.words)
+	}
+	if b.pendingBegin {
+		b.pendingBegin = false
+		switch w {
+		case "TRAN", "TRANSACTION", "DISTRIBUTED", "DIALOG", "CONVERSATION":
+			// statements, not blocks
+		default:
+			b.depth++
+		}
+	}
+	switch w {
+	case "BEGIN":
+		b.pendingBegin = true
+	case "CASE":
+		b.depth++
+	case "END":
+		if b.depth > 0 {
+			b.depth--
+		}
+	}
+}
+
+// isModuleStart reports whether words start a CREATE or ALTER of a
+// procedure, function or trigger.
+func isModuleStart(words []string) bool {
+	if len(words) < 2 || (words[0] != "CREATE" && words[0] != "ALTER") {
+		return false
+	}
+	kind := words[1]
+	if words[0] == "CREATE" && kind == "OR" {
+		if len(words) < 4 || words[2] != "ALTER" {
+			return false
+		}
+		kind = words[3]
+	}
+	switch kind {
+	case "PROC", "PROCEDURE", "FUNCTION", "TRIGGER":
+		return true
+	}
+	return false
+}
+
+func (b *stmtBuffer) flush() string {
+	stmt := strings.TrimSpace(strings.Join(b.lines, "\n"))
+	*b = stmtBuffer{}
+	return stmt
+}
+
+func (b *stmtBuffer) empty() bool {
+	return len(b.lines) == 0
+}
+
+func (sh *shell) prompt(continuation bool) string {
+	name := sh.database
+	if name == "" {
+		name = "?"
+	}
+	tx := ""
+	if sh.tranCnt > 0 {
+		tx = "*"
+	}
+	if continuation {
+		return strings.Repeat(" ", len(name)) + tx + "-> "
+	}
+	return name + tx + "=> "
+}
+
+func (sh *shell) run(ctx context.Context, rl *readline.Instance) error {
+	sh.refreshState(ctx)
+	var buf stmtBuffer
+	for {
+		rl.SetPrompt(sh.prompt(!buf.empty()))
+		line, err := rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			buf.flush()
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if buf.empty() && strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			quit := sh.meta(ctx, strings.Fields(strings.TrimSpace(line)), &buf)
+			if quit {
+				break
+			}
+			continue
+		}
+
+		stmt, complete := buf.add(line)
+		if !complete || stmt == "" {
+			continue
+		}
+		if err := sh.exec(ctx, stmt); err != nil {
+			fmt.Fprintf(sh.out, "error: %v\n", err)
+		}
+		sh.refreshState(ctx)
+	}
+	if sh.tranCnt > 0 {
+		log.Warnw("leaving shell with open transaction, rolling back", "trancount", sh.tranCnt)
+	}
+	return nil
+}
+
+// meta executes a backslash command. It returns true if the shell should quit.
+func (sh *shell) meta(ctx context.Context, args []string, buf *stmtBuffer) bool {
+	switch args[0] {
+	case `\q`:
+		return true
+	case `\?`:
+		fmt.Fprintln(sh.out, shellHelp)
+	case `\c`:
+		buf.flush()
+	case `\timing`:
+		sh.timing = !sh.timing
+		state := "off"
+		if sh.timing {
+			state = "on"
+		}
+		fmt.Fprintf(sh.out, "timing is %s\n", state)
+	case `\format`:
+		if len(args) < 2 {
+			fmt.Fprintf(sh.out, "format is %s\n", sh.format)
+			break
+		}
+		format, err := mssqlload.ParseFormat(args[1])
+		if err != nil {
+			fmt.Fprintf(sh.out, "error: %v\n", err)
+			break
+		}
+		sh.format = format
+	case `\dn`:
+		sh.query(ctx, `SELECT s.name AS [schema], p.name AS [owner]
+FROM sys.schemas s JOIN sys.database_principals p ON p.principal_id = s.principal_id
+ORDER BY s.name`)
+	case `\d`:
+		if len(args) < 2 {
+			sh.query(ctx, `SELECT TABLE_SCHEMA AS [schema], TABLE_NAME AS [name], TABLE_TYPE AS [type]
+FROM INFORMATION_SCHEMA.TABLES ORDER BY TABLE_SCHEMA, TABLE_NAME`)
+			break
+		}
+		schema, table := mssqlload.SplitTableName(args[1])
+		sh.query(ctx, `SELECT COLUMN_NAME AS [column], DATA_TYPE AS [type],
+  CHARACTER_MAXIMUM_LENGTH AS [length], IS_NULLABLE AS [nullable], COLUMN_DEFAULT AS [default]
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA = @p1 AND TABLE_NAME = @p2
+ORDER BY ORDINAL_POSITION`, schema, table)
+	default:
+		fmt.Fprintf(sh.out, "unknown command %s, try \\?\n", args[0])
+	}
+	return false
+}
+
+func (sh *shell) query(ctx context.Context, q string, args ...any) {
+	if err := sh.exec(ctx, q, args...); err != nil {
+		fmt.Fprintf(sh.out, "error: %v\n", err)
+	}
+}
+
+func (sh *shell) exec(ctx context.Context, stmt string, args ...any) error {
+	// Ctrl-C only cancels the running statement, not the shell
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	if sh.stmtTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sh.stmtTimeout)
+		defer cancel()
+	}
+	start := time.Now()
+	rows, err := sh.conn.QueryxContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for {
+		cols, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		if len(cols) > 0 {
+			n, err := mssqlload.WriteRows(sh.out, rows.Rows, sh.format)
+			if err != nil {
+				return err
+			}
+			if sh.format == mssqlload.FormatTable {
+				fmt.Fprintf(sh.out, "(%d rows)\n", n)
+			}
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if sh.timing {
+		fmt.Fprintf(sh.out, "Time: %s\n", time.Since(start).Round(time.Microsecond))
+	}
+	return nil
+}
+
+func (sh *shell) refreshState(ctx context.Context) {
+	row := sh.conn.QueryRowxContext(ctx, "SELECT @@TRANCOUNT, DB_NAME()")
+	if err := row.Scan(&sh.tranCnt, &sh.database); err != nil {
+		log.Warnw("could not determine session state", zap.Error(err))
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import "testing"
+
+func TestStmtBuffer(t *testing.T) {
+	var buf stmtBuffer
+	if _, complete := buf.add("select name"); complete {
+		t.Fatal("statement should not be complete yet")
+	}
+	stmt, complete := buf.add("from pokemon.pokemon;")
+	if !complete {
+		t.Fatal("statement should be complete after ;")
+	}
+	if stmt != "select name\nfrom pokemon.pokemon;" {
+		t.Fatalf("unexpected statement %q", stmt)
+	}
+
+	buf.add("select 1")
+	stmt, complete = buf.add("  go ")
+	if !complete || stmt != "select 1" {
+		t.Fatalf("GO should terminate the statement, got %q", stmt)
+	}
+	if !buf.empty() {
+		t.Fatal("buffer should be empty after a complete statement")
+	}
+}
+
+func TestStmtBufferBlocks(t *testing.T) {
+	cases := []struct {
+		name  string
+		lines []string
+		// complete is the index of the line that completes the statement,
+		// -1 if none does
+		complete int
+	}{
+		{"string", []string{"select 'a;", "b';"}, 1},
+		{"comment", []string{"select 1 /* a;", "*/ + 1; -- done"}, 1},
+		{"line comment", []string{"select 1 -- a;", "+ 1;"}, 1},
+		{"begin end", []string{"if 1 = 1", "begin", "  print 'a';", "  print 'b';", "end;"}, 4},
+		{"case", []string{"select case when 1 = 1", "then 'a;' else 'b' end;"}, 1},
+		{"begin tran", []string{"begin tran;"}, 0},
+		{"try catch", []string{"begin try", "  select 1/0;", "end try", "begin catch", "  print 'x';", "end catch;"}, 5},
+		{"procedure", []string{"create or alter procedure p as", "begin", "  select 1;", "end;"}, -1},
+		{"function", []string{"CREATE FUNCTION f() RETURNS int AS", "BEGIN RETURN 1; END;"}, -1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf stmtBuffer
+			got := -1
+			for i, line := range c.lines {
+				if _, complete := buf.add(line); complete {
+					got = i
+					break
+				}
+			}
+			if got != c.complete {
+				t.Fatalf("expected completion at line %d, got %d", c.complete, got)
+			}
+			if got == -1 {
+				if stmt, complete := buf.add("GO"); !complete || stmt == "" {
+					t.Fatal("GO should terminate the statement")
+				}
+			}
+		})
+	}
+}
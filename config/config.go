@@ -1,29 +1,262 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 )
 
 type Config struct {
-	Name                       string
+	Name string
 	User string
 	Pass string
-	Host string
-	Port                       string
+	// PassFile and PassCommand are alternative sources for Pass, see
+	// ResolvePassword.
+	PassFile            string
+	PassCommand         string
+	Host                string
+	Port                string
 	WithEncryption      string
 	WithTrustServerCert string
+	// CAFile, HostnameInCert and TLSMin configure the verification of the
+	// server certificate, ClientCert and ClientKey the client certificate.
+	CAFile         string
+	HostnameInCert string
+	TLSMin         string
+	ClientCert     string
+	ClientKey      string
+	// Instance is the named instance, resolved via the SQL Server Browser
+	Instance            string
+	FailoverPartner     string
+	AppName             string
+	ConnTimeout         string
+	DialTimeout         string
+	PacketSize          string
+	ApplicationIntent   string
+	MultiSubnetFailover string
+	// Auth is the authentication mode, one of AuthModes.
+	Auth       string
+	TokenFile  string
+	TenantID   string
+	Krb5Config string
+	Krb5Keytab string
+	Krb5Realm  string
+	// DSN, if set, takes precedence over all other fields.
+	DSN string
+	// Profile is the name of the profile the config is based on, if any.
+	Profile string
+	// Sources records for every field where its value came from, e.g.
+	// "default", "env MSSQL_HOST", "profile ci" or "flag --host".
+	Sources map[string]string
+}
+
+// AuthModes lists the supported authentication modes.
+var AuthModes = []string{"sql", "azure-sp", "azure-msi", "azure-cli", "token", "krb5"}
+
+// Fields lists the names of all settings, as used by Set, Get and Sources.
+var Fields = []string{
+	"name", "host", "instance", "port", "user", "pass", "pass-file", "pass-command", "encrypt", "trust-server-cert",
+	"ca-file", "hostname-in-certificate", "tls-min", "client-cert", "client-key",
+	"failover-partner", "app-name", "connection-timeout", "dial-timeout", "packet-size",
+	"application-intent", "multi-subnet-failover",
+	"auth", "token-file", "tenant-id", "krb5-config", "krb5-keytab", "krb5-realm",
+	"dsn",
 }
 
 func New() Config {
-	return Config{
-		Name:                getEnv("MSSQL_NAME", "master"),
-		User:                getEnv("MSSQL_USER", "sa"),
-		Pass:                getEnv("MSSQL_PASS", ""),
-		Host:                getEnv("MSSQL_HOST", "localhost"),
-		Port:                getEnv("MSSQL_PORT", "1433"),
-		WithEncryption:      getEnv("MSSQL_ENCRYPT", "false"),
-		WithTrustServerCert: getEnv("MSSQL_TRUST_SERVER_CERT", "true"),
+	return NewWithProfile("", nil)
+}
+
+// NewWithProfile resolves the config from env vars, the given profile and
+// the defaults, in that order of precedence. The profile may be nil.
+func NewWithProfile(profileName string, p *Profile) Config {
+	if p == nil {
+		p = &Profile{}
+	}
+	cfg := Config{Profile: profileName, Sources: map[string]string{}}
+	cfg.Name = cfg.resolve("name", "MSSQL_NAME", profileName, p.Name, "master")
+	cfg.User = cfg.resolve("user", "MSSQL_USER", profileName, p.User, "sa")
+	cfg.Pass = cfg.resolve("pass", "MSSQL_PASS", profileName, p.Pass, "")
+	cfg.PassFile = cfg.resolve("pass-file", "MSSQL_PASS_FILE", profileName, p.PassFile, "")
+	cfg.PassCommand = cfg.resolve("pass-command", "MSSQL_PASS_COMMAND", profileName, p.PassCommand, "")
+	cfg.Host = cfg.resolve("host", "MSSQL_HOST", profileName, p.Host, "localhost")
+	cfg.Port = cfg.resolve("port", "MSSQL_PORT", profileName, p.Port, "1433")
+	cfg.WithEncryption = cfg.resolve("encrypt", "MSSQL_ENCRYPT", profileName, p.Encrypt, "false")
+	cfg.WithTrustServerCert = cfg.resolve("trust-server-cert", "MSSQL_TRUST_SERVER_CERT", profileName, p.TrustServerCert, "true")
+	cfg.CAFile = cfg.resolve("ca-file", "MSSQL_CA_FILE", profileName, p.CAFile, "")
+	cfg.HostnameInCert = cfg.resolve("hostname-in-certificate", "MSSQL_HOSTNAME_IN_CERTIFICATE", profileName, p.HostnameInCert, "")
+	cfg.TLSMin = cfg.resolve("tls-min", "MSSQL_TLS_MIN", profileName, p.TLSMin, "")
+	cfg.ClientCert = cfg.resolve("client-cert", "MSSQL_CLIENT_CERT", profileName, p.ClientCert, "")
+	cfg.ClientKey = cfg.resolve("client-key", "MSSQL_CLIENT_KEY", profileName, p.ClientKey, "")
+	cfg.Instance = cfg.resolve("instance", "MSSQL_INSTANCE", profileName, p.Instance, "")
+	cfg.FailoverPartner = cfg.resolve("failover-partner", "MSSQL_FAILOVER_PARTNER", profileName, p.FailoverPartner, "")
+	cfg.AppName = cfg.resolve("app-name", "MSSQL_APP_NAME", profileName, p.AppName, "")
+	cfg.ConnTimeout = cfg.resolve("connection-timeout", "MSSQL_CONNECTION_TIMEOUT", profileName, p.ConnTimeout, "")
+	cfg.DialTimeout = cfg.resolve("dial-timeout", "MSSQL_DIAL_TIMEOUT", profileName, p.DialTimeout, "")
+	cfg.PacketSize = cfg.resolve("packet-size", "MSSQL_PACKET_SIZE", profileName, p.PacketSize, "")
+	cfg.ApplicationIntent = cfg.resolve("application-intent", "MSSQL_APPLICATION_INTENT", profileName, p.ApplicationIntent, "")
+	cfg.MultiSubnetFailover = cfg.resolve("multi-subnet-failover", "MSSQL_MULTI_SUBNET_FAILOVER", profileName, p.MultiSubnetFailover, "")
+	cfg.Auth = cfg.resolve("auth", "MSSQL_AUTH", profileName, p.Auth, "sql")
+	cfg.TokenFile = cfg.resolve("token-file", "MSSQL_TOKEN_FILE", profileName, p.TokenFile, "")
+	cfg.TenantID = cfg.resolve("tenant-id", "MSSQL_TENANT_ID", profileName, p.TenantID, "")
+	cfg.Krb5Config = cfg.resolve("krb5-config", "MSSQL_KRB5_CONFIG", profileName, p.Krb5Config, "")
+	cfg.Krb5Keytab = cfg.resolve("krb5-keytab", "MSSQL_KRB5_KEYTAB", profileName, p.Krb5Keytab, "")
+	cfg.Krb5Realm = cfg.resolve("krb5-realm", "MSSQL_KRB5_REALM", profileName, p.Krb5Realm, "")
+	cfg.DSN = cfg.resolve("dsn", "MSSQL_DSN", profileName, p.DSN, "")
+	return cfg
+}
+
+func (c *Config) field(name string) *string {
+	switch name {
+	case "name":
+		return &c.Name
+	case "user":
+		return &c.User
+	case "pass":
+		return &c.Pass
+	case "pass-file":
+		return &c.PassFile
+	case "pass-command":
+		return &c.PassCommand
+	case "host":
+		return &c.Host
+	case "port":
+		return &c.Port
+	case "encrypt":
+		return &c.WithEncryption
+	case "trust-server-cert":
+		return &c.WithTrustServerCert
+	case "ca-file":
+		return &c.CAFile
+	case "hostname-in-certificate":
+		return &c.HostnameInCert
+	case "tls-min":
+		return &c.TLSMin
+	case "client-cert":
+		return &c.ClientCert
+	case "client-key":
+		return &c.ClientKey
+	case "instance":
+		return &c.Instance
+	case "failover-partner":
+		return &c.FailoverPartner
+	case "app-name":
+		return &c.AppName
+	case "connection-timeout":
+		return &c.ConnTimeout
+	case "dial-timeout":
+		return &c.DialTimeout
+	case "packet-size":
+		return &c.PacketSize
+	case "application-intent":
+		return &c.ApplicationIntent
+	case "multi-subnet-failover":
+		return &c.MultiSubnetFailover
+	case "auth":
+		return &c.Auth
+	case "token-file":
+		return &c.TokenFile
+	case "tenant-id":
+		return &c.TenantID
+	case "krb5-config":
+		return &c.Krb5Config
+	case "krb5-keytab":
+		return &c.Krb5Keytab
+	case "krb5-realm":
+		return &c.Krb5Realm
+	case "dsn":
+		return &c.DSN
+	}
+	return nil
+}
+
+// DSNOverrides returns the sources of the fields that are set by a flag or
+// env var, while DSN comes from a profile. The DSN would silently win over
+// them, against the precedence flag > env var > profile.
+func (c Config) DSNOverrides() []string {
+	if c.DSN == "" || !strings.HasPrefix(c.Sources["dsn"], "profile ") {
+		return nil
+	}
+	var res []string
+	for _, name := range Fields {
+		src := c.Sources[name]
+		if name != "dsn" && (strings.HasPrefix(src, "flag ") || strings.HasPrefix(src, "env ")) {
+			res = append(res, src)
+		}
+	}
+	return res
+}
+
+// Validate checks the combination of the settings, e.g. that the auth mode
+// is known and has the settings it needs.
+func (cfg Config) Validate() error {
+	if overrides := cfg.DSNOverrides(); len(overrides) > 0 {
+		return fmt.Errorf("the dsn of %s cannot be combined with %s, remove the dsn from the profile or use --dsn",
+			cfg.Sources["dsn"], strings.Join(overrides, ", "))
+	}
+	if err := cfg.validateAuth(); err != nil {
+		return err
+	}
+	return cfg.validateTLS()
+}
+
+func (cfg Config) validateAuth() error {
+	switch cfg.Auth {
+	case "sql", "azure-msi", "azure-cli", "krb5":
+	case "azure-sp":
+		if cfg.Sources["user"] == "default" {
+			return errors.New("--auth azure-sp requires the client id as --user")
+		}
+	case "token":
+		if cfg.TokenFile == "" {
+			return errors.New("--auth token requires --token-file")
+		}
+	default:
+		return fmt.Errorf("unknown auth mode %q, supported: %s", cfg.Auth, strings.Join(AuthModes, ", "))
+	}
+	return nil
+}
+
+func (cfg Config) validateTLS() error {
+	switch cfg.TLSMin {
+	case "", "1.0", "1.1", "1.2", "1.3":
+	default:
+		return fmt.Errorf("invalid tls-min %q, expected 1.0, 1.1, 1.2 or 1.3", cfg.TLSMin)
+	}
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return errors.New("client-cert and client-key have to be specified together")
+	}
+	return nil
+}
+
+// Set overrides a field value and records its source.
+func (c *Config) Set(field string, value string, source string) {
+	if f := c.field(field); f != nil {
+		*f = value
+		c.Sources[field] = source
+	}
+}
+
+// Get returns the value of a field, see Fields.
+func (c Config) Get(field string) string {
+	if f := c.field(field); f != nil {
+		return *f
+	}
+	return ""
+}
+
+func (c *Config) resolve(field string, envKey string, profileName string, profileValue string, fallback string) string {
+	if value, exists := os.LookupEnv(envKey); exists {
+		c.Sources[field] = "env " + envKey
+		return value
+	}
+	if profileValue != "" {
+		c.Sources[field] = "profile " + profileName
+		return profileValue
 	}
+	c.Sources[field] = "default"
+	return fallback
 }
 
 func getEnv(key string, fallback string) string {
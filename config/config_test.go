@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFile = `default_profile: local
+profiles:
+  local:
+    user: sa
+    pass: Passw0rd
+  ci:
+    host: mssql
+    port: 1433
+    name: pokedb
+`
+
+func TestFindAndLoadFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, FileName), []byte(testFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := FindFiles(sub)
+	if len(files) != 1 || files[0] != filepath.Join(root, FileName) {
+		t.Fatalf("unexpected config files %v", files)
+	}
+	f, err := LoadFiles(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, p, err := f.Profile("")
+	if err != nil || name != "local" || p.Pass != "Passw0rd" {
+		t.Fatalf("expected default profile local, got %s %v %v", name, p, err)
+	}
+	if _, _, err := f.Profile("staging"); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	t.Setenv("MSSQL_HOST", "envhost")
+	p := &Profile{Host: "profilehost", Name: "pokedb"}
+	cfg := NewWithProfile("ci", p)
+
+	if cfg.Host != "envhost" || cfg.Sources["host"] != "env MSSQL_HOST" {
+		t.Errorf("env should take precedence over profile, got %s (%s)", cfg.Host, cfg.Sources["host"])
+	}
+	if cfg.Name != "pokedb" || cfg.Sources["name"] != "profile ci" {
+		t.Errorf("profile should take precedence over default, got %s (%s)", cfg.Name, cfg.Sources["name"])
+	}
+	if cfg.Port != "1433" || cfg.Sources["port"] != "default" {
+		t.Errorf("expected default port, got %s (%s)", cfg.Port, cfg.Sources["port"])
+	}
+	cfg.Set("host", "flaghost", "flag --host")
+	if cfg.Host != "flaghost" || cfg.Sources["host"] != "flag --host" {
+		t.Errorf("flag should take precedence over env, got %s (%s)", cfg.Host, cfg.Sources["host"])
+	}
+}
+
+func TestResolvePassword(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "pass")
+	if err := os.WriteFile(f, []byte("filepw\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MSSQL_PASS_FILE", f)
+
+	cfg := NewWithProfile("ci", &Profile{Pass: "profilepw"})
+	if err := cfg.ResolvePassword(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Pass != "filepw" || cfg.Sources["pass"] != "env MSSQL_PASS_FILE" {
+		t.Errorf("env pass file should win over profile pass, got %s (%s)", cfg.Pass, cfg.Sources["pass"])
+	}
+
+	cfg.Set("pass-command", "echo cmdpw", "flag --pass-command")
+	if err := cfg.ResolvePassword(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Pass != "cmdpw" {
+		t.Errorf("flag pass command should win over env pass file, got %s", cfg.Pass)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	r := strings.NewReader("s3cret\r\nrest of stdin")
+	pass, err := ReadPassword(r)
+	if err != nil || pass != "s3cret" {
+		t.Fatalf("got %q %v", pass, err)
+	}
+	if r.Len() != len("rest of stdin") {
+		t.Errorf("read more than the first line")
+	}
+}
+
+func TestLogSettings(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yaml")
+	project := filepath.Join(dir, "project.yaml")
+	if err := os.WriteFile(user, []byte("log:\n  level: debug\n  format: json\n  quiet: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(project, []byte("log:\n  format: logfmt\n  driver: \"63\"\n  quiet: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFiles(user, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Log.Level != "debug" || f.Log.Format != "logfmt" || f.Log.Driver != "63" || f.Log.Quiet == nil || *f.Log.Quiet {
+		t.Fatalf("the project file should override the user file, got %+v", f.Log)
+	}
+
+	t.Setenv("MSSQL_LOG_LEVEL", "warn")
+	t.Setenv("MSSQL_QUIET", "true")
+	s, err := f.Log.WithEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level != "warn" || !s.IsQuiet() || s.Format != "logfmt" {
+		t.Fatalf("env should take precedence over the config file, got %+v", s)
+	}
+	t.Setenv("MSSQL_QUIET", "maybe")
+	if _, err := f.Log.WithEnv(); err == nil {
+		t.Fatal("expected error for invalid MSSQL_QUIET")
+	}
+}
+
+func TestLintSettings(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yaml")
+	project := filepath.Join(dir, "project.yaml")
+	if err := os.WriteFile(user, []byte("lint:\n  rules:\n    drop-if-exists: off\n    use-database: error\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(project, []byte("lint:\n  rules:\n    use-database: warning\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFiles(user, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Lint.Rules["drop-if-exists"] != "off" || f.Lint.Rules["use-database"] != "warning" {
+		t.Fatalf("unexpected lint rules %v", f.Lint.Rules)
+	}
+}
+
+func TestDSNOverrides(t *testing.T) {
+	t.Setenv("MSSQL_HOST", "envhost")
+	cfg := NewWithProfile("ci", &Profile{DSN: "sqlserver://sa@mssql"})
+	cfg.Set("name", "pokedb", "flag --name")
+	overrides := cfg.DSNOverrides()
+	if strings.Join(overrides, ",") != "flag --name,env MSSQL_HOST" {
+		t.Errorf("unexpected overrides %v", overrides)
+	}
+
+	cfg.Set("dsn", "sqlserver://sa@other", "flag --dsn")
+	if overrides := cfg.DSNOverrides(); overrides != nil {
+		t.Errorf("an explicit dsn should take precedence, got overrides %v", overrides)
+	}
+}
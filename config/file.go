@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the project config file, it is searched for in the
+// current working directory and all its parents.
+const FileName = ".go-mssql-load.yaml"
+
+// Profile is a named set of connection settings in the config file. Empty
+// fields are not set by the profile.
+type Profile struct {
+	Name                string `yaml:"name"`
+	Host                string `yaml:"host"`
+	Port                string `yaml:"port"`
+	User                string `yaml:"user"`
+	Pass                string `yaml:"pass"`
+	PassFile            string `yaml:"pass_file"`
+	PassCommand         string `yaml:"pass_command"`
+	Encrypt             string `yaml:"encrypt"`
+	TrustServerCert     string `yaml:"trust_server_cert"`
+	CAFile              string `yaml:"ca_file"`
+	HostnameInCert      string `yaml:"hostname_in_certificate"`
+	TLSMin              string `yaml:"tls_min"`
+	ClientCert          string `yaml:"client_cert"`
+	ClientKey           string `yaml:"client_key"`
+	Instance            string `yaml:"instance"`
+	FailoverPartner     string `yaml:"failover_partner"`
+	AppName             string `yaml:"app_name"`
+	ConnTimeout         string `yaml:"connection_timeout"`
+	DialTimeout         string `yaml:"dial_timeout"`
+	PacketSize          string `yaml:"packet_size"`
+	ApplicationIntent   string `yaml:"application_intent"`
+	MultiSubnetFailover string `yaml:"multi_subnet_failover"`
+	Auth                string `yaml:"auth"`
+	TokenFile           string `yaml:"token_file"`
+	TenantID            string `yaml:"tenant_id"`
+	Krb5Config          string `yaml:"krb5_config"`
+	Krb5Keytab          string `yaml:"krb5_keytab"`
+	Krb5Realm           string `yaml:"krb5_realm"`
+	DSN                 string `yaml:"dsn"`
+}
+
+type File struct {
+	// DefaultProfile is used if no profile is selected explicitly.
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+	Log            LogSettings        `yaml:"log"`
+	Lint           LintSettings       `yaml:"lint"`
+}
+
+// LintSettings configure the lint command.
+type LintSettings struct {
+	// Rules maps rule names to severities: error, warning or off.
+	Rules map[string]string `yaml:"rules"`
+}
+
+// LogSettings configure logging, independent of the profile.
+type LogSettings struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is console, json or logfmt.
+	Format string `yaml:"format"`
+	File string `yaml:"file"`
+	// Quiet is nil if not set, so that a later file can turn it off.
+	Quiet *bool `yaml:"quiet"`
+	// Driver is the log bitmask of go-mssqldb, e.g. 63 for everything.
+	Driver string `yaml:"driver"`
+}
+
+// FindFiles returns the config files that apply to dir, ordered from lowest
+// to highest priority: the user config file
+// (~/.config/go-mssql-load/config.yaml) and the nearest FileName found in dir
+// or one of its parents.
+func FindFiles(dir string) []string {
+	var files []string
+	if cfgDir, err := os.UserConfigDir(); err == nil {
+		p := filepath.Join(cfgDir, "go-mssql-load", "config.yaml")
+		if isFile(p) {
+			files = append(files, p)
+		}
+	}
+	for d := dir; ; {
+		p := filepath.Join(d, FileName)
+		if isFile(p) {
+			files = append(files, p)
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return files
+}
+
+func isFile(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// DefaultFiles returns the config file given by MSSQL_CONFIG, or else the
+// ones found for the current directory, see FindFiles.
+func DefaultFiles() []string {
+	if v, ok := os.LookupEnv("MSSQL_CONFIG"); ok {
+		return []string{v}
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		return FindFiles(cwd)
+	}
+	return nil
+}
+
+// Load resolves the config from env vars, a profile of the config files
+// and the defaults. profileName selects the profile, if empty MSSQL_PROFILE
+// or the default profile of the files is used.
+func Load(profileName string, files ...string) (Config, error) {
+	f, err := LoadFiles(files...)
+	if err != nil {
+		return Config{}, err
+	}
+	profileName, profile, err := f.Profile(profileName)
+	if err != nil {
+		return Config{}, err
+	}
+	if profile == nil {
+		profileName = ""
+	}
+	return NewWithProfile(profileName, profile), nil
+}
+
+// LoadFiles reads and merges the given config files. Profiles and settings in
+// later files override those in earlier files.
+func LoadFiles(paths ...string) (File, error) {
+	merged := File{Profiles: map[string]Profile{}}
+	for _, p := range paths {
+		raw, err := os.ReadFile(p)
+		if err != nil {
+			return File{}, err
+		}
+		var f File
+		if err := yaml.Unmarshal(raw, &f); err != nil {
+			return File{}, fmt.Errorf("could not parse config file %s: %w", p, err)
+		}
+		if f.DefaultProfile != "" {
+			merged.DefaultProfile = f.DefaultProfile
+		}
+		for name, profile := range f.Profiles {
+			merged.Profiles[name] = profile
+		}
+		merged.Log = merged.Log.merge(f.Log)
+		for rule, severity := range f.Lint.Rules {
+			if merged.Lint.Rules == nil {
+				merged.Lint.Rules = map[string]string{}
+			}
+			merged.Lint.Rules[rule] = severity
+		}
+	}
+	return merged, nil
+}
+
+// Profile returns the profile with the given name. If name is empty, the
+// profile from {MSSQL_PROFILE} or the default profile of the file is used.
+// If no profile is selected at all, nil is returned.
+func (f File) Profile(name string) (string, *Profile, error) {
+	if name == "" {
+		name = getEnv("MSSQL_PROFILE", f.DefaultProfile)
+	}
+	if name == "" {
+		return "", nil, nil
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		return name, nil, fmt.Errorf("profile %q not found in config files", name)
+	}
+	return name, &p, nil
+}
+
+// merge overrides the settings with the non-empty settings of o.
+func (s LogSettings) merge(o LogSettings) LogSettings {
+	for _, f := range []struct{ dst, src *string }{
+		{&s.Level, &o.Level}, {&s.Format, &o.Format}, {&s.File, &o.File}, {&s.Driver, &o.Driver},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if o.Quiet != nil {
+		s.Quiet = o.Quiet
+	}
+	return s
+}
+
+// WithEnv overrides the settings with the env vars MSSQL_LOG_LEVEL,
+// MSSQL_LOG_FORMAT, MSSQL_LOG_FILE, MSSQL_QUIET and MSSQL_DRIVER_LOG.
+func (s LogSettings) WithEnv() (LogSettings, error) {
+	s.Level = getEnv("MSSQL_LOG_LEVEL", s.Level)
+	s.Format = getEnv("MSSQL_LOG_FORMAT", s.Format)
+	s.File = getEnv("MSSQL_LOG_FILE", s.File)
+	s.Driver = getEnv("MSSQL_DRIVER_LOG", s.Driver)
+	if v, ok := os.LookupEnv("MSSQL_QUIET"); ok {
+		quiet, err := strconv.ParseBool(v)
+		if err != nil {
+			return s, fmt.Errorf("invalid MSSQL_QUIET %q: %w", v, err)
+		}
+		s.Quiet = &quiet
+	}
+	return s, nil
+}
+
+// IsQuiet reports whether only errors are logged.
+func (s LogSettings) IsQuiet() bool {
+	return s.Quiet != nil && *s.Quiet
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// sourceRank orders sources by precedence: flag > env > profile > default.
+func sourceRank(source string) int {
+	switch {
+	case strings.HasPrefix(source, "flag"):
+		return 3
+	case strings.HasPrefix(source, "env"):
+		return 2
+	case strings.HasPrefix(source, "profile"):
+		return 1
+	}
+	return 0
+}
+
+// ResolvePassword determines the password from pass, pass-file or
+// pass-command, whichever was set with the highest precedence. On a tie, pass
+// wins over pass-file, which wins over pass-command. File contents and command
+// output are used without surrounding whitespace.
+func (c *Config) ResolvePassword() error {
+	best, bestRank := "", -1
+	for _, f := range []string{"pass", "pass-file", "pass-command"} {
+		if c.Get(f) == "" {
+			continue
+		}
+		if r := sourceRank(c.Sources[f]); r > bestRank {
+			best, bestRank = f, r
+		}
+	}
+
+	switch best {
+	case "pass-file":
+		raw, err := os.ReadFile(c.PassFile)
+		if err != nil {
+			return fmt.Errorf("could not read password file (%s): %w", c.Sources["pass-file"], err)
+		}
+		c.Set("pass", strings.TrimSpace(string(raw)), c.Sources["pass-file"])
+	case "pass-command":
+		pass, err := runPassCommand(c.PassCommand)
+		if err != nil {
+			return fmt.Errorf("password command failed (%s): %w", c.Sources["pass-command"], err)
+		}
+		c.Set("pass", pass, c.Sources["pass-command"])
+	}
+	return nil
+}
+
+func runPassCommand(command string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	// don't hand the password env var down to the child process
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "MSSQL_PASS=") {
+			cmd.Env = append(cmd.Env, e)
+		}
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	pass := strings.TrimSpace(string(out))
+	if pass == "" {
+		return "", errors.New("command returned an empty password")
+	}
+	return pass, nil
+}
+
+// ReadPassword reads a single line from r. It reads byte by byte, so nothing
+// after the line is consumed.
+func ReadPassword(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	mssql "github.com/microsoft/go-mssqldb"
+	_ "github.com/microsoft/go-mssqldb/integratedauth/krb5"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// Values of the fedauth DSN parameter that select Entra ID (Azure AD)
+// authentication.
+const (
+	FedAuthServicePrincipal = "ActiveDirectoryServicePrincipal"
+	FedAuthManagedIdentity  = "ActiveDirectoryManagedIdentity"
+	FedAuthAzCli            = "ActiveDirectoryAzCli"
+)
+
+// AccessTokenFileParam is the DSN parameter pointing to a file with a
+// pre-fetched access token. It is handled by go-mssql-load, not the driver.
+const AccessTokenFileParam = "accesstokenfile"
+
+const azureSQLScope = "https://database.windows.net/.default"
+
+// NewAzureCredential creates the credential for a fedauth workflow. It can be
+// replaced, e.g. by a fake credential in tests.
+var NewAzureCredential = func(workflow string, cfg msdsn.Config) (azcore.TokenCredential, error) {
+	switch workflow {
+	case FedAuthServicePrincipal:
+		clientID, tenantID, _ := strings.Cut(cfg.User, "@")
+		if clientID == "" || tenantID == "" {
+			return nil, errors.New("service principal authentication requires the client id and the tenant id")
+		}
+		return azidentity.NewClientSecretCredential(tenantID, clientID, cfg.Password, nil)
+	case FedAuthManagedIdentity:
+		opts := &azidentity.ManagedIdentityCredentialOptions{}
+		if cfg.User != "" {
+			opts.ID = azidentity.ClientID(cfg.User)
+		}
+		return azidentity.NewManagedIdentityCredential(opts)
+	case FedAuthAzCli:
+		return azidentity.NewAzureCLICredential(nil)
+	}
+	return nil, fmt.Errorf("unsupported fedauth %q", workflow)
+}
+
+// NewConnector creates a connector for the dsn, taking the authentication
+// mode into account.
+func NewConnector(dsn *url.URL) (*mssql.Connector, error) {
+	cfg, err := msdsn.Parse(dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	return newConnector(cfg)
+}
+
+// newConnector creates the connector for a parsed dsn, so that callers can
+// adjust the config, e.g. the TLS settings, for every authentication mode.
+func newConnector(cfg msdsn.Config) (*mssql.Connector, error) {
+	if workflow := cfg.Parameters["fedauth"]; workflow != "" {
+		if cfg.Parameters[ClientCertParam] != "" || cfg.Parameters[ClientKeyParam] != "" {
+			return nil, errors.New("client certificates are not supported with Entra ID authentication")
+		}
+		cred, err := NewAzureCredential(workflow, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return mssql.NewSecurityTokenConnector(cfg, azureTokenProvider(cred))
+	}
+	if err := applyClientCert(&cfg); err != nil {
+		return nil, err
+	}
+	if f := cfg.Parameters[AccessTokenFileParam]; f != "" {
+		return mssql.NewSecurityTokenConnector(cfg, func(ctx context.Context) (string, error) {
+			return readTokenFile(f)
+		})
+	}
+	return mssql.NewConnectorConfig(cfg), nil
+}
+
+// azureTokenProvider fetches an access token for Azure SQL on every connect.
+// The credential caches the token until it expires.
+func azureTokenProvider(cred azcore.TokenCredential) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureSQLScope}})
+		if err != nil {
+			return "", fmt.Errorf("%w: could not get access token: %v", ErrAuth, err)
+		}
+		return token.Token, nil
+	}
+}
+
+func readTokenFile(f string) (string, error) {
+	raw, err := os.ReadFile(f)
+	if err != nil {
+		return "", fmt.Errorf("could not read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return "", fmt.Errorf("%w: token file %s is empty", ErrAuth, f)
+	}
+	return token, nil
+}
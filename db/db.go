@@ -2,50 +2,187 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/microsoft/go-mssqldb"
+	"github.com/jwbargsten/go-mssql-load/util"
+	mssql "github.com/microsoft/go-mssqldb"
 	"go.uber.org/zap"
+	"math/rand"
+	"net"
 	"net/url"
+	"strings"
 	"time"
 )
 
+var (
+	ErrTimeout = errors.New("timed out waiting for db")
+	ErrAuth    = errors.New("authentication failed")
+	ErrNetwork = errors.New("network failure")
+)
+
 func Open(dsn *url.URL) (*sqlx.DB, error) {
+	connector, err := NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sqlx.NewDb(sql.OpenDB(connector), "sqlserver"), nil
+}
+
+// Backoff describes an exponential backoff with jitter.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter is the fraction (0-1) by which a delay is randomly varied.
+	Jitter float64
+}
+
+var DefaultBackoff = Backoff{
+	Initial:    100 * time.Millisecond,
+	Max:        10 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Delay returns the time to wait before the given attempt (starting at 1).
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := float64(b.Initial)
+	for i := 1; i < attempt; i++ {
+		d *= b.Multiplier
+		if b.Max > 0 && d >= float64(b.Max) {
+			d = float64(b.Max)
+			break
+		}
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
 
-	db, err := sqlx.Open("sqlserver", dsn.String())
-	return db, err
+type WaitOptions struct {
+	Backoff Backoff
+	// MaxAttempts limits the number of connection attempts, 0 means no limit.
+	// The overall duration should be limited via the context.
+	MaxAttempts int
+	// Database, if set, is waited for until its state is ONLINE.
+	Database string
+	// Object, if set, is waited for until it exists. Unless fully qualified,
+	// it is resolved in Database (or the current database).
+	Object string
 }
 
 // StatusCheck returns nil if it can successfully talk to the database. It
 // returns a non-nil error otherwise.
 func StatusCheck(ctx context.Context, db *sqlx.DB, log *zap.SugaredLogger) error {
+	return Wait(ctx, db, log, WaitOptions{Backoff: DefaultBackoff, MaxAttempts: 6})
+}
 
-	// First check we can ping the database.
-	var pingError error
+// Wait blocks until the database can be reached and all conditions in opts
+// are met. Errors are classified, see ClassifyError. Authentication failures
+// are not retried.
+func Wait(ctx context.Context, db *sqlx.DB, log *zap.SugaredLogger, opts WaitOptions) error {
+	var lastErr error
 	for attempts := 1; ; attempts++ {
-		pingError = db.Ping()
-		if pingError == nil {
-			break
+		lastErr = ClassifyError(probe(ctx, db, opts))
+		if lastErr == nil {
+			return nil
+		}
+		if errors.Is(lastErr, ErrAuth) {
+			return lastErr
 		}
-		log.Warnw("could not ping db", zap.Error(pingError))
-		time.Sleep(time.Duration(attempts) * 100 * time.Millisecond)
-		if ctx.Err() != nil {
-			return ctx.Err()
+		if opts.MaxAttempts > 0 && attempts >= opts.MaxAttempts {
+			return fmt.Errorf("could not make connection (tried %d times): %w", attempts, lastErr)
 		}
-		if attempts >= 6 {
-			return fmt.Errorf("could not make connection (tried %d times)", attempts)
+		delay := opts.Backoff.Delay(attempts)
+		log.Warnw("db not ready", "attempt", attempts, "retry_in", delay.Round(time.Millisecond), zap.Error(lastErr))
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w after %d attempts: %v", ErrTimeout, attempts, lastErr)
+		case <-time.After(delay):
 		}
 	}
+}
 
-	// Make sure we didn't timeout or be cancelled.
-	if ctx.Err() != nil {
-		return ctx.Err()
+func probe(ctx context.Context, db *sqlx.DB, opts WaitOptions) error {
+	if err := db.PingContext(ctx); err != nil {
+		return err
 	}
 
 	// Run a simple query to determine connectivity. Running this query forces a
 	// round trip through the database.
 	const q = `SELECT COUNT(*) FROM sys.databases`
 	var tmp string
-	err := db.QueryRowContext(ctx, q).Scan(&tmp)
+	if err := db.QueryRowContext(ctx, q).Scan(&tmp); err != nil {
+		return err
+	}
+
+	if opts.Database != "" {
+		var state string
+		err := db.QueryRowContext(ctx, `SELECT state_desc FROM sys.databases WHERE name = @p1`, opts.Database).Scan(&state)
+		if err != nil {
+			return fmt.Errorf("database %s not found: %w", opts.Database, err)
+		}
+		if state != "ONLINE" {
+			return fmt.Errorf("database %s is %s", opts.Database, state)
+		}
+	}
+
+	if opts.Object != "" {
+		object := qualifyObject(opts.Database, opts.Object)
+		var id *int64
+		if err := db.QueryRowContext(ctx, `SELECT OBJECT_ID(@p1)`, object).Scan(&id); err != nil {
+			return err
+		}
+		if id == nil {
+			return fmt.Errorf("object %s does not exist", object)
+		}
+	}
+	return nil
+}
+
+// qualifyObject prefixes object with database, unless database is empty or
+// object already names a database. A one-part name becomes [db]..object,
+// so that it is resolved in the default schema, not as schema.object.
+func qualifyObject(database, object string) string {
+	if database == "" {
+		return object
+	}
+	prefix := "[" + strings.ReplaceAll(database, "]", "]]") + "]."
+	switch len(util.SplitName(object)) {
+	case 1:
+		return prefix + "." + object
+	case 2:
+		return prefix + object
+	}
+	return object
+}
+
+// ClassifyError wraps err with ErrTimeout, ErrAuth or ErrNetwork, if it can be
+// attributed to one of those causes.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrAuth) || errors.Is(err, ErrNetwork) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) && sqlErr.Number == 18456 {
+		return fmt.Errorf("%w: %v", ErrAuth, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", ErrNetwork, err)
+	}
 	return err
 }
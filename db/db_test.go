@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if d := b.Delay(i + 1); d != e {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, e, d)
+		}
+	}
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := b.Delay(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("delay with jitter out of range: %s", d)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected error
+	}{
+		{fmt.Errorf("ping: %w", context.DeadlineExceeded), ErrTimeout},
+		{mssql.Error{Number: 18456, Message: "Login failed for user 'sa'."}, ErrAuth},
+		{fmt.Errorf("unable to open tcp connection: %w", &net.OpError{Op: "dial", Err: errors.New("refused")}), ErrNetwork},
+	}
+	for _, c := range cases {
+		if err := ClassifyError(c.err); !errors.Is(err, c.expected) {
+			t.Errorf("expected %v to be classified as %v, got %v", c.err, c.expected, err)
+		}
+	}
+	if ClassifyError(nil) != nil {
+		t.Error("nil should stay nil")
+	}
+}
+
+func TestQualifyObject(t *testing.T) {
+	cases := []struct{ database, object, expected string }{
+		{"", "pokemon", "pokemon"},
+		{"poke", "pokemon", "[poke]..pokemon"},
+		{"poke", "pokemon.pokemon", "[poke].pokemon.pokemon"},
+		{"poke", "[a.b].[c]", "[poke].[a.b].[c]"},
+		{"poke", "[a.b]", "[poke]..[a.b]"},
+		{"poke", "other.pokemon.pokemon", "other.pokemon.pokemon"},
+	}
+	for _, c := range cases {
+		if got := qualifyObject(c.database, c.object); got != c.expected {
+			t.Errorf("qualifyObject(%q, %q) = %q, expected %q", c.database, c.object, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// CheckItem is a single diagnostic result of Diagnose.
+type CheckItem struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type tlsCapture struct {
+	mu    sync.Mutex
+	state *tls.ConnectionState
+}
+
+func (c *tlsCapture) get() *tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// openWithTLSCapture opens the db like Open, but records the state of the
+// TLS handshake so it can be inspected afterwards.
+func openWithTLSCapture(dsn *url.URL) (*sqlx.DB, *tlsCapture, error) {
+	cfg, err := msdsn.Parse(dsn.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	capture := &tlsCapture{}
+	if cfg.TLSConfig != nil {
+		cfg.TLSConfig = cfg.TLSConfig.Clone()
+		cfg.TLSConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			capture.mu.Lock()
+			defer capture.mu.Unlock()
+			capture.state = &cs
+			return nil
+		}
+	}
+	connector, err := newConnector(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	con := sql.OpenDB(connector)
+	con.SetMaxOpenConns(1)
+	return sqlx.NewDb(con, "sqlserver"), capture, nil
+}
+
+// Diagnose collects information about the server, the session and the
+// permissions of the current login. Each piece of information is reported
+// as separate item. An error is only returned if no connection could be made.
+func Diagnose(ctx context.Context, dsn *url.URL) ([]CheckItem, error) {
+	con, capture, err := openWithTLSCapture(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer con.Close()
+	if err := con.PingContext(ctx); err != nil {
+		return nil, ClassifyError(err)
+	}
+
+	var items []CheckItem
+
+	start := time.Now()
+	_, err = con.ExecContext(ctx, "SELECT 1")
+	items = append(items, item("latency", time.Since(start).Round(time.Microsecond).String(), err))
+
+	queries := []struct {
+		name string
+		q    string
+	}{
+		{"version", "SELECT @@VERSION"},
+		{"product version", "SELECT CAST(SERVERPROPERTY('ProductVersion') AS nvarchar(128))"},
+		{"edition", "SELECT CAST(SERVERPROPERTY('Edition') AS nvarchar(128))"},
+		{"login", "SELECT SUSER_SNAME()"},
+		{"database", "SELECT DB_NAME()"},
+		{"collation", "SELECT CAST(DATABASEPROPERTYEX(DB_NAME(), 'Collation') AS nvarchar(128))"},
+		{"compatibility level", "SELECT CAST(compatibility_level AS nvarchar(10)) FROM sys.databases WHERE name = DB_NAME()"},
+		{"encryption", "SELECT encrypt_option FROM sys.dm_exec_connections WHERE session_id = @@SPID"},
+	}
+	for _, v := range queries {
+		var res sql.NullString
+		err := con.QueryRowContext(ctx, v.q).Scan(&res)
+		items = append(items, item(v.name, firstLine(res.String), err))
+	}
+
+	encrypt := dsn.Query().Get("encrypt")
+	if encrypt == "" {
+		encrypt = "false"
+	}
+	items = append(items, CheckItem{Name: "encrypt mode", OK: true, Value: encrypt})
+	items = append(items, tlsItems(capture.get())...)
+
+	permissions := []struct {
+		name string
+		q    string
+	}{
+		{"permission CREATE TABLE", "SELECT HAS_PERMS_BY_NAME(DB_NAME(), 'DATABASE', 'CREATE TABLE')"},
+		{"permission ADMINISTER BULK OPERATIONS", `SELECT COALESCE(HAS_PERMS_BY_NAME(NULL, NULL, 'ADMINISTER BULK OPERATIONS'), 0)
+  | COALESCE(HAS_PERMS_BY_NAME(DB_NAME(), 'DATABASE', 'ADMINISTER DATABASE BULK OPERATIONS'), 0)`},
+	}
+	for _, v := range permissions {
+		var granted sql.NullInt64
+		err := con.QueryRowContext(ctx, v.q).Scan(&granted)
+		it := item(v.name, "granted", err)
+		if err == nil && granted.Int64 != 1 {
+			it = CheckItem{Name: v.name, OK: false, Value: "denied"}
+		}
+		items = append(items, it)
+	}
+
+	return items, nil
+}
+
+func item(name string, value string, err error) CheckItem {
+	if err != nil {
+		return CheckItem{Name: name, OK: false, Error: err.Error()}
+	}
+	return CheckItem{Name: name, OK: true, Value: value}
+}
+
+// firstLine returns the first line of a value, e.g. of @@VERSION, which
+// spans multiple lines.
+func firstLine(value string) string {
+	line, _, _ := strings.Cut(value, "\n")
+	return strings.TrimSpace(line)
+}
+
+func tlsItems(cs *tls.ConnectionState) []CheckItem {
+	if cs == nil {
+		return []CheckItem{{Name: "tls", OK: false, Error: "no TLS handshake took place"}}
+	}
+	items := []CheckItem{
+		{Name: "tls version", OK: true, Value: tls.VersionName(cs.Version)},
+		{Name: "tls cipher suite", OK: true, Value: tls.CipherSuiteName(cs.CipherSuite)},
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return append(items, CheckItem{Name: "server certificate", OK: false, Error: "no certificate presented"})
+	}
+	cert := cs.PeerCertificates[0]
+	verified := "not verified"
+	if len(cs.VerifiedChains) > 0 {
+		verified = "verified"
+	}
+	return append(items,
+		CheckItem{Name: "server certificate", OK: true, Value: fmt.Sprintf("subject=%q issuer=%q (%s)", cert.Subject.String(), cert.Issuer.String(), verified)},
+		CheckItem{Name: "server certificate validity", OK: time.Now().Before(cert.NotAfter), Value: fmt.Sprintf("%s - %s", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))},
+	)
+}
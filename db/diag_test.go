@@ -0,0 +1,78 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestItem(t *testing.T) {
+	version := "Microsoft SQL Server 2022 (RTM-CU12) - 16.0.4125.3 (X64) \n\tMar  1 2024 14:33:46 \n\tCopyright (C) 2022 Microsoft Corporation\n"
+	cases := []struct {
+		name     string
+		value    string
+		err      error
+		expected CheckItem
+	}{
+		{"version", firstLine(version), nil, CheckItem{Name: "version", OK: true, Value: "Microsoft SQL Server 2022 (RTM-CU12) - 16.0.4125.3 (X64)"}},
+		{"product version", firstLine("16.0.4125.3"), nil, CheckItem{Name: "product version", OK: true, Value: "16.0.4125.3"}},
+		{"edition", firstLine(""), errors.New("permission denied"), CheckItem{Name: "edition", OK: false, Error: "permission denied"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := item(c.name, c.value, c.err); got != c.expected {
+				t.Errorf("expected %+v, got %+v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestTLSItems(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "mssql"},
+		Issuer:    pkix.Name{CommonName: "ca"},
+		NotBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	expired := *cert
+	expired.NotAfter = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	conn := tls.ConnectionState{Version: tls.VersionTLS12, CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+	tlsVersion := CheckItem{Name: "tls version", OK: true, Value: "TLS 1.2"}
+	cipher := CheckItem{Name: "tls cipher suite", OK: true, Value: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}
+	withCert := func(c *x509.Certificate, verified bool) *tls.ConnectionState {
+		cs := conn
+		cs.PeerCertificates = []*x509.Certificate{c}
+		if verified {
+			cs.VerifiedChains = [][]*x509.Certificate{{c}}
+		}
+		return &cs
+	}
+
+	cases := []struct {
+		name     string
+		cs       *tls.ConnectionState
+		expected []CheckItem
+	}{
+		{"no handshake", nil, []CheckItem{{Name: "tls", OK: false, Error: "no TLS handshake took place"}}},
+		{"no certificate", &conn, []CheckItem{tlsVersion, cipher, {Name: "server certificate", OK: false, Error: "no certificate presented"}}},
+		{"verified", withCert(cert, true), []CheckItem{tlsVersion, cipher,
+			{Name: "server certificate", OK: true, Value: `subject="CN=mssql" issuer="CN=ca" (verified)`},
+			{Name: "server certificate validity", OK: true, Value: "2024-01-01T00:00:00Z - 2099-01-01T00:00:00Z"},
+		}},
+		{"expired and not verified", withCert(&expired, false), []CheckItem{tlsVersion, cipher,
+			{Name: "server certificate", OK: true, Value: `subject="CN=mssql" issuer="CN=ca" (not verified)`},
+			{Name: "server certificate validity", OK: false, Value: "2024-01-01T00:00:00Z - 2025-01-01T00:00:00Z"},
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := tlsItems(c.cs); !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("expected %+v, got %+v", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jwbargsten/go-mssql-load/config"
+	"github.com/jwbargsten/go-mssql-load/util"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// ParseDSN parses a DSN in URL form (sqlserver://...), ADO form
+// (Server=...;Database=...) or ODBC form (odbc:server=...;database=...) and
+// returns it in URL form.
+func ParseDSN(dsn string) (*url.URL, error) {
+	if strings.HasPrefix(dsn, "sqlserver://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// url.Error contains the complete dsn, including the password
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, err
+		}
+		return u, nil
+	}
+
+	cfg, err := msdsn.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	u := &url.URL{Scheme: "sqlserver", Host: cfg.Host}
+	if cfg.Port > 0 {
+		u.Host = net.JoinHostPort(cfg.Host, strconv.FormatUint(cfg.Port, 10))
+	}
+	if cfg.Instance != "" {
+		u.Path = "/" + cfg.Instance
+	}
+	if cfg.User != "" || cfg.Password != "" {
+		u.User = url.UserPassword(cfg.User, cfg.Password)
+	}
+	q := url.Values{}
+	for k, v := range cfg.Parameters {
+		switch k {
+		case msdsn.Server, msdsn.Port, msdsn.UserID, msdsn.Password:
+			continue
+		}
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// connString builds the DSN from the individual settings of cfg.
+func connString(cfg config.Config) *url.URL {
+	q := make(url.Values)
+	q.Add("encrypt", cfg.WithEncryption)
+	q.Add("database", cfg.Name)
+	q.Add("TrustServerCertificate", cfg.WithTrustServerCert)
+	for k, v := range map[string]string{
+		"certificate":           cfg.CAFile,
+		"hostnameincertificate": cfg.HostnameInCert,
+		"tlsmin":                cfg.TLSMin,
+		ClientCertParam:         cfg.ClientCert,
+		ClientKeyParam:          cfg.ClientKey,
+		"failoverpartner":       cfg.FailoverPartner,
+		"app name":              cfg.AppName,
+		"connection timeout":    cfg.ConnTimeout,
+		"dial timeout":          cfg.DialTimeout,
+		"packet size":           cfg.PacketSize,
+		"ApplicationIntent":     cfg.ApplicationIntent,
+		"MultiSubnetFailover":   cfg.MultiSubnetFailover,
+	} {
+		if v != "" {
+			q.Add(k, v)
+		}
+	}
+	host, instance, _ := strings.Cut(cfg.Host, `\`)
+	if cfg.Instance != "" {
+		instance = cfg.Instance
+	}
+	if instance == "" || cfg.Sources["port"] != "default" {
+		host = fmt.Sprintf("%s:%s", host, cfg.Port)
+	}
+	user := url.UserPassword(cfg.User, cfg.Pass)
+	switch cfg.Auth {
+	case "azure-sp":
+		q.Add("fedauth", FedAuthServicePrincipal)
+		if cfg.TenantID != "" {
+			user = url.UserPassword(cfg.User+"@"+cfg.TenantID, cfg.Pass)
+		}
+	case "azure-msi":
+		q.Add("fedauth", FedAuthManagedIdentity)
+		// the user is the optional client id of a user-assigned identity
+		user = nil
+		if cfg.Sources["user"] != "default" {
+			user = url.User(cfg.User)
+		}
+	case "azure-cli":
+		q.Add("fedauth", FedAuthAzCli)
+		user = nil
+	case "token":
+		q.Add(AccessTokenFileParam, cfg.TokenFile)
+		user = nil
+	case "krb5":
+		q.Add("authenticator", "krb5")
+		for k, v := range map[string]string{
+			"krb5-configfile": cfg.Krb5Config,
+			"krb5-keytabfile": cfg.Krb5Keytab,
+			"krb5-realm":      cfg.Krb5Realm,
+		} {
+			if v != "" {
+				q.Add(k, v)
+			}
+		}
+		if cfg.Pass == "" {
+			user = url.User(cfg.User)
+		}
+	}
+	dbUrl := url.URL{
+		Scheme:   "sqlserver",
+		User:     user,
+		Host:     host,
+		RawQuery: q.Encode(),
+	}
+	if instance != "" {
+		dbUrl.Path = "/" + instance
+	}
+	return &dbUrl
+}
+
+// DSNFromConfig returns the DSN of cfg: cfg.DSN if set, otherwise the DSN
+// built from the individual settings. The password of cfg.DSN is registered
+// as secret.
+func DSNFromConfig(cfg config.Config) (*url.URL, error) {
+	if cfg.DSN == "" {
+		return connString(cfg), nil
+	}
+	dsn, err := ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse dsn (%s): %w", cfg.Sources["dsn"], err)
+	}
+	if pass, ok := dsn.User.Password(); ok {
+		util.AddSecret(pass)
+	}
+	return dsn, nil
+}
+
+// WithDatabase returns a copy of dsn that connects to the given database.
+func WithDatabase(dsn *url.URL, database string) *url.URL {
+	u := *dsn
+	q := u.Query()
+	for k := range q {
+		if strings.EqualFold(k, "database") {
+			q.Del(k)
+		}
+	}
+	q.Set("database", database)
+	u.RawQuery = q.Encode()
+	return &u
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jwbargsten/go-mssql-load/util"
+)
+
+// RecoveryModels lists the recovery models of CreateOptions.
+var RecoveryModels = []string{"SIMPLE", "FULL", "BULK_LOGGED"}
+
+type CreateOptions struct {
+	// Collation of the database, the server default if empty.
+	Collation string
+	// RecoveryModel is SIMPLE, FULL or BULK_LOGGED, the default of the
+	// model database if empty.
+	RecoveryModel string
+}
+
+var collationName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+var systemDatabases = map[string]bool{"master": true, "model": true, "msdb": true, "tempdb": true}
+
+// createStatements returns the statements that create the database name.
+func createStatements(name string, opts CreateOptions) ([]string, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	stmt := "CREATE DATABASE " + util.QuoteName(name)
+	if opts.Collation != "" {
+		// collations cannot be passed as parameter
+		if !collationName.MatchString(opts.Collation) {
+			return nil, fmt.Errorf("invalid collation %q", opts.Collation)
+		}
+		stmt += " COLLATE " + opts.Collation
+	}
+	stmts := []string{stmt}
+	if opts.RecoveryModel != "" {
+		model := strings.ToUpper(opts.RecoveryModel)
+		valid := false
+		for _, m := range RecoveryModels {
+			valid = valid || m == model
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid recovery model %q, expected %s", opts.RecoveryModel, strings.Join(RecoveryModels, ", "))
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER DATABASE %s SET RECOVERY %s", util.QuoteName(name), model))
+	}
+	return stmts, nil
+}
+
+func checkName(name string) error {
+	if name == "" {
+		return fmt.Errorf("database name is empty")
+	}
+	if systemDatabases[strings.ToLower(name)] {
+		return fmt.Errorf("%s is a system database", name)
+	}
+	return nil
+}
+
+// CreateDatabase creates the database name. db must not be connected to a
+// transaction, CREATE DATABASE is not allowed in transactions.
+func CreateDatabase(ctx context.Context, db *sqlx.DB, name string, opts CreateOptions) error {
+	stmts, err := createStatements(name, opts)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return util.ContextError(ctx, err)
+		}
+	}
+	return nil
+}
+
+// DatabaseExists reports whether the database name exists.
+func DatabaseExists(ctx context.Context, db *sqlx.DB, name string) (bool, error) {
+	var id *int64
+	if err := db.QueryRowContext(ctx, "SELECT DB_ID(@p1)", name).Scan(&id); err != nil {
+		return false, util.ContextError(ctx, err)
+	}
+	return id != nil, nil
+}
+
+// dropStatement returns the statement that drops the database name. With
+// force, the other sessions are disconnected and their transactions rolled
+// back first.
+func dropStatement(name string, force bool) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+	stmt := "DROP DATABASE " + util.QuoteName(name)
+	if force {
+		stmt = fmt.Sprintf("ALTER DATABASE %[1]s SET SINGLE_USER WITH ROLLBACK IMMEDIATE; DROP DATABASE %[1]s", util.QuoteName(name))
+	}
+	return stmt, nil
+}
+
+// DropDatabase drops the database name, if it exists, and reports whether it
+// existed. Without force, it fails if the database is in use.
+func DropDatabase(ctx context.Context, db *sqlx.DB, name string, force bool) (bool, error) {
+	stmt, err := dropStatement(name, force)
+	if err != nil {
+		return false, err
+	}
+	exists, err := DatabaseExists(ctx, db, name)
+	if err != nil || !exists {
+		return false, err
+	}
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return true, util.ContextError(ctx, err)
+	}
+	return true, nil
+}
+
+// DatabaseCollation returns the collation of the database name.
+func DatabaseCollation(ctx context.Context, db *sqlx.DB, name string) (string, error) {
+	var collation sql.NullString
+	err := db.QueryRowContext(ctx, "SELECT collation_name FROM sys.databases WHERE name = @p1", name).Scan(&collation)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("database %s does not exist", name)
+	}
+	return collation.String, util.ContextError(ctx, err)
+}
+
+// BackupFile is a file of a database backup, see RESTORE FILELISTONLY.
+type BackupFile struct {
+	LogicalName  string
+	PhysicalName string
+	// Type is D for data and L for log files.
+	Type string
+}
+
+// CloneDatabase copies the database src to dst on the same server with
+// BACKUP DATABASE ... WITH COPY_ONLY and RESTORE DATABASE. The backup is
+// written to backupDir on the server, which defaults to the default backup
+// directory of the instance. The files of dst are placed in the default data
+// and log directories. The backup file, named <src>_<dst>_clone.bak so that
+// clones to different targets do not overwrite each other's backups, is not
+// removed.
+func CloneDatabase(ctx context.Context, db *sqlx.DB, src, dst string, backupDir string) error {
+	if err := checkName(dst); err != nil {
+		return err
+	}
+	var dataDir, logDir, defaultBackupDir string
+	err := db.QueryRowContext(ctx, `SELECT CAST(SERVERPROPERTY('InstanceDefaultDataPath') AS nvarchar(4000)),
+  CAST(SERVERPROPERTY('InstanceDefaultLogPath') AS nvarchar(4000)),
+  CAST(COALESCE(SERVERPROPERTY('InstanceDefaultBackupPath'), SERVERPROPERTY('InstanceDefaultDataPath')) AS nvarchar(4000))`).
+		Scan(&dataDir, &logDir, &defaultBackupDir)
+	if err != nil {
+		return fmt.Errorf("could not read the default directories: %w", util.ContextError(ctx, err))
+	}
+	if backupDir == "" {
+		backupDir = defaultBackupDir
+	}
+	backup := serverPath(backupDir, src+"_"+dst+"_clone.bak")
+
+	stmt := fmt.Sprintf("BACKUP DATABASE %s TO DISK = %s WITH COPY_ONLY, INIT, FORMAT", util.QuoteName(src), quoteString(backup))
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("could not back up %s: %w", src, util.ContextError(ctx, err))
+	}
+	files, err := backupFiles(ctx, db, backup)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, restoreStatement(dst, backup, files, dataDir, logDir)); err != nil {
+		return fmt.Errorf("could not restore %s: %w", dst, util.ContextError(ctx, err))
+	}
+	return nil
+}
+
+func backupFiles(ctx context.Context, db *sqlx.DB, backup string) ([]BackupFile, error) {
+	rows, err := db.QueryxContext(ctx, "RESTORE FILELISTONLY FROM DISK = "+quoteString(backup))
+	if err != nil {
+		return nil, util.ContextError(ctx, err)
+	}
+	defer rows.Close()
+	var files []BackupFile
+	for rows.Next() {
+		// the result has many columns, which differ between versions
+		row := map[string]any{}
+		if err := rows.MapScan(row); err != nil {
+			return nil, err
+		}
+		var f BackupFile
+		f.LogicalName, _ = row["LogicalName"].(string)
+		f.PhysicalName, _ = row["PhysicalName"].(string)
+		f.Type, _ = row["Type"].(string)
+		files = append(files, f)
+	}
+	return files, util.ContextError(ctx, rows.Err())
+}
+
+// restoreStatement returns the RESTORE statement that moves the files of the
+// backup to files named after dst.
+func restoreStatement(dst, backup string, files []BackupFile, dataDir, logDir string) string {
+	var moves []string
+	for _, f := range files {
+		dir := dataDir
+		if f.Type == "L" {
+			dir = logDir
+		}
+		// replace backslashes, so that path.Ext works for Windows paths
+		target := serverPath(dir, dst+"_"+f.LogicalName+path.Ext(strings.ReplaceAll(f.PhysicalName, `\`, "/")))
+		moves = append(moves, fmt.Sprintf("MOVE %s TO %s", quoteString(f.LogicalName), quoteString(target)))
+	}
+	return fmt.Sprintf("RESTORE DATABASE %s FROM DISK = %s WITH %s, RECOVERY",
+		util.QuoteName(dst), quoteString(backup), strings.Join(moves, ", "))
+}
+
+// serverPath joins dir and name with the path separator of the server,
+// which is derived from dir.
+func serverPath(dir, name string) string {
+	sep := "/"
+	if strings.Contains(dir, `\`) && !strings.Contains(dir, "/") {
+		sep = `\`
+	}
+	return strings.TrimRight(dir, sep) + sep + name
+}
+
+func quoteString(s string) string {
+	return "N'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCreateStatements(t *testing.T) {
+	stmts, err := createStatements("poke]db", CreateOptions{Collation: "Latin1_General_100_CI_AS_SC_UTF8", RecoveryModel: "simple"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"CREATE DATABASE [poke]]db] COLLATE Latin1_General_100_CI_AS_SC_UTF8",
+		"ALTER DATABASE [poke]]db] SET RECOVERY SIMPLE",
+	}
+	if !reflect.DeepEqual(stmts, expected) {
+		t.Errorf("expected %v, got %v", expected, stmts)
+	}
+	for _, opts := range []CreateOptions{{Collation: "x; DROP"}, {RecoveryModel: "partial"}} {
+		if _, err := createStatements("pokedb", opts); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+	if _, err := createStatements("Master", CreateOptions{}); err == nil {
+		t.Error("expected error for system database")
+	}
+}
+
+func TestDropStatement(t *testing.T) {
+	stmt, _ := dropStatement("pokedb", false)
+	if stmt != "DROP DATABASE [pokedb]" {
+		t.Errorf("unexpected %q", stmt)
+	}
+	stmt, _ = dropStatement("pokedb", true)
+	if stmt != "ALTER DATABASE [pokedb] SET SINGLE_USER WITH ROLLBACK IMMEDIATE; DROP DATABASE [pokedb]" {
+		t.Errorf("unexpected %q", stmt)
+	}
+	if _, err := dropStatement("tempdb", true); err == nil {
+		t.Error("expected error for system database")
+	}
+}
+
+func TestRestoreStatement(t *testing.T) {
+	files := []BackupFile{
+		{LogicalName: "pokedb", PhysicalName: "/var/opt/mssql/data/pokedb.mdf", Type: "D"},
+		{LogicalName: "pokedb_log", PhysicalName: "/var/opt/mssql/data/pokedb_log.ldf", Type: "L"},
+	}
+	stmt := restoreStatement("copy", "/backup/pokedb_copy_clone.bak", files, "/var/opt/mssql/data/", "/var/opt/mssql/log")
+	expected := "RESTORE DATABASE [copy] FROM DISK = N'/backup/pokedb_copy_clone.bak' WITH " +
+		"MOVE N'pokedb' TO N'/var/opt/mssql/data/copy_pokedb.mdf', " +
+		"MOVE N'pokedb_log' TO N'/var/opt/mssql/log/copy_pokedb_log.ldf', RECOVERY"
+	if stmt != expected {
+		t.Errorf("expected %q, got %q", expected, stmt)
+	}
+}
+
+func TestServerPath(t *testing.T) {
+	cases := map[[2]string]string{
+		{"/var/opt/mssql/data/", "a.bak"}: "/var/opt/mssql/data/a.bak",
+		{`C:\Data\`, "a.bak"}:             `C:\Data\a.bak`,
+		{`C:\Data`, "a.bak"}:              `C:\Data\a.bak`,
+	}
+	for in, expected := range cases {
+		if got := serverPath(in[0], in[1]); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+}
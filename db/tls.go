@@ -0,0 +1,34 @@
+package db
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// DSN parameters with the PEM files of the client certificate and key. They
+// are handled by go-mssql-load, not the driver.
+const (
+	ClientCertParam = "clientcertificate"
+	ClientKeyParam  = "clientkey"
+)
+
+// applyClientCert adds the client certificate configured in the DSN to the
+// TLS config.
+func applyClientCert(cfg *msdsn.Config) error {
+	certFile, keyFile := cfg.Parameters[ClientCertParam], cfg.Parameters[ClientKeyParam]
+	if certFile == "" && keyFile == "" {
+		return nil
+	}
+	if cfg.TLSConfig == nil {
+		return fmt.Errorf("client certificate requires encryption, but encrypt is %q", cfg.Parameters["encrypt"])
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load client certificate: %w", err)
+	}
+	cfg.TLSConfig = cfg.TLSConfig.Clone()
+	cfg.TLSConfig.Certificates = append(cfg.TLSConfig.Certificates, cert)
+	return nil
+}
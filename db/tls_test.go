@@ -0,0 +1,75 @@
+package db
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+func writeSelfSignedCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-mssql-load-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestApplyClientCert(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+	q := url.Values{}
+	q.Set("encrypt", "true")
+	q.Set(ClientCertParam, certFile)
+	q.Set(ClientKeyParam, keyFile)
+	dsn := url.URL{Scheme: "sqlserver", Host: "localhost:1433", RawQuery: q.Encode()}
+
+	cfg, err := msdsn.Parse(dsn.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyClientCert(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.TLSConfig.Certificates) != 1 {
+		t.Fatalf("expected client certificate in TLS config")
+	}
+
+	q.Set("encrypt", "disable")
+	dsn.RawQuery = q.Encode()
+	cfg, _ = msdsn.Parse(dsn.String())
+	if err := applyClientCert(&cfg); err == nil {
+		t.Fatal("expected error for client certificate without encryption")
+	}
+}
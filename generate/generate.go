@@ -0,0 +1,668 @@
+// Package generate creates synthetic, type-correct rows for a table, based
+// on its column types and constraints. Columns can be customized with a
+// spec:
+//
+//	columns:
+//	  name: {generator: first_name}
+//	  hp: {min: 1, max: 255, distribution: normal, mean: 80, stddev: 20}
+//	  type: {values: [fire, water, grass], weights: [1, 2, 1]}
+//	  legendary: {null_ratio: 0.5}
+//	  created_at: {skip: true}
+package generate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwbargsten/go-mssql-load/schema"
+	mssql "github.com/microsoft/go-mssqldb"
+	"gopkg.in/yaml.v3"
+)
+
+type Spec struct {
+	Columns map[string]ColumnSpec `yaml:"columns"`
+}
+
+type ColumnSpec struct {
+	// Generator is one of sequence, int, float, bool, word, sentence, name,
+	// first_name, last_name, email, city, uuid or date. By default it is
+	// derived from the column type.
+	Generator string `yaml:"generator"`
+	// Values is an enumeration to choose from, optionally weighted.
+	Values  []any     `yaml:"values"`
+	Weights []float64 `yaml:"weights"`
+	// Min and Max limit numbers (and dates, as years).
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+	// Distribution of numbers: uniform (default) or normal.
+	Distribution string  `yaml:"distribution"`
+	Mean         float64 `yaml:"mean"`
+	Stddev       float64 `yaml:"stddev"`
+	// NullRatio is the fraction of NULL values, the default for nullable
+	// columns is 0.1.
+	NullRatio *float64 `yaml:"null_ratio"`
+	// Skip leaves the column to its default value.
+	Skip bool `yaml:"skip"`
+}
+
+func LoadSpec(f string) (Spec, error) {
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return Spec{}, err
+	}
+	return ParseSpec(b)
+}
+
+// ParseSpec parses a YAML or JSON spec.
+func ParseSpec(b []byte) (Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return Spec{}, err
+	}
+	return spec, nil
+}
+
+type Options struct {
+	Seed int64
+	// ParentKeys are the sampled keys of the referenced tables, by column.
+	ParentKeys map[string][]any
+	// SequenceStart is the first value of generated unique integer columns,
+	// by column, e.g. to continue after the existing rows.
+	SequenceStart map[string]int64
+}
+
+const defaultNullRatio = 0.1
+
+type valueFunc func(row int64) (any, error)
+
+// Generator generates the rows for the columns returned by Columns.
+type Generator struct {
+	columns []string
+	funcs   []valueFunc
+	// keys are the positions of the columns of composite unique indexes.
+	keys []uniqueKey
+}
+
+type uniqueKey struct {
+	columns []int
+	seen    map[string]bool
+}
+
+// Columns returns the columns that are generated, identity, computed and
+// skipped columns are omitted.
+func (g *Generator) Columns() []string {
+	return g.columns
+}
+
+// Row generates the values of row i (starting at 0).
+func (g *Generator) Row(i int64) ([]any, error) {
+	row := make([]any, len(g.funcs))
+	for j := range g.funcs {
+		if err := g.value(row, i, j); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range g.keys {
+		if err := g.uniqueTuple(row, i, k); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+func (g *Generator) value(row []any, i int64, j int) error {
+	v, err := g.funcs[j](i)
+	if err != nil {
+		return fmt.Errorf("column %s: %w", g.columns[j], err)
+	}
+	row[j] = v
+	return nil
+}
+
+// uniqueTuple regenerates the columns of k until their values were not
+// generated before. NULLs are compared like values, as by unique indexes.
+func (g *Generator) uniqueTuple(row []any, i int64, k uniqueKey) error {
+	for attempt := 0; attempt < 100; attempt++ {
+		vals := make([]any, len(k.columns))
+		for n, j := range k.columns {
+			vals[n] = row[j]
+		}
+		key := fmt.Sprint(vals...)
+		if !k.seen[key] {
+			k.seen[key] = true
+			return nil
+		}
+		for _, j := range k.columns {
+			if err := g.value(row, i, j); err != nil {
+				return err
+			}
+		}
+	}
+	names := make([]string, len(k.columns))
+	for n, j := range k.columns {
+		names[n] = g.columns[j]
+	}
+	return fmt.Errorf("columns %s: could not generate a unique combination after 100 attempts, use a spec with a larger value range", strings.Join(names, ", "))
+}
+
+// UniqueColumns returns the columns of single-column unique indexes and
+// primary keys.
+func UniqueColumns(t *schema.Table) map[string]bool {
+	res := map[string]bool{}
+	for _, idx := range t.Indexes {
+		if idx.Unique && len(idx.Columns) == 1 {
+			res[idx.Columns[0]] = true
+		}
+	}
+	return res
+}
+
+// compositeUniqueKeys returns the columns of unique indexes and primary keys
+// with more than one column. Keys that contain an identity column are unique
+// anyway and omitted.
+func compositeUniqueKeys(t *schema.Table) [][]string {
+	identity := map[string]bool{}
+	for _, c := range t.Columns {
+		identity[c.Name] = c.Identity
+	}
+	var res [][]string
+	for _, idx := range t.Indexes {
+		if !idx.Unique || len(idx.Columns) < 2 {
+			continue
+		}
+		generated := true
+		for _, c := range idx.Columns {
+			generated = generated && !identity[c]
+		}
+		if generated {
+			res = append(res, idx.Columns)
+		}
+	}
+	return res
+}
+
+// ForeignKeyColumns returns the referenced table and column of all
+// single-column foreign keys, by column.
+func ForeignKeyColumns(t *schema.Table) map[string][2]string {
+	res := map[string][2]string{}
+	for _, fk := range t.ForeignKeys {
+		if len(fk.Columns) == 1 {
+			res[fk.Columns[0]] = [2]string{fk.RefTable, fk.RefColumns[0]}
+		}
+	}
+	return res
+}
+
+// New creates a generator for the table. All random values are derived from
+// opts.Seed, so the same seed generates the same rows.
+func New(t *schema.Table, spec Spec, opts Options) (*Generator, error) {
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	unique := UniqueColumns(t)
+	fks := ForeignKeyColumns(t)
+
+	known := map[string]bool{}
+	for _, c := range t.Columns {
+		known[c.Name] = true
+	}
+	for name := range spec.Columns {
+		if !known[name] {
+			return nil, fmt.Errorf("spec contains unknown column %s", name)
+		}
+	}
+
+	g := &Generator{}
+	for _, col := range t.Columns {
+		cs, hasSpec := spec.Columns[col.Name]
+		if col.Identity || col.Computed || cs.Skip || baseType(col.Type) == "timestamp" || baseType(col.Type) == "rowversion" {
+			continue
+		}
+		var fn valueFunc
+		var err error
+		switch {
+		case !hasSpec && fks[col.Name] != [2]string{}:
+			fn, err = sampleFunc(rnd, col, opts.ParentKeys[col.Name])
+		case !hasSpec && unique[col.Name] && isIntType(col.Type):
+			start := opts.SequenceStart[col.Name]
+			if start == 0 {
+				start = 1
+			}
+			fn = func(row int64) (any, error) { return start + row, nil }
+		case !hasSpec && unique[col.Name] && isStringType(col.Type):
+			fn = uniqueWordFunc(rnd, col)
+		default:
+			fn, err = columnFunc(rnd, col, cs)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		fn = convertFunc(col, fn)
+		if unique[col.Name] {
+			fn = uniqueFunc(fn)
+		}
+		nullRatio, err := nullRatio(col, cs, unique[col.Name])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		if nullRatio > 0 {
+			fn = nullableFunc(rnd, fn, nullRatio)
+		}
+		g.columns = append(g.columns, col.Name)
+		g.funcs = append(g.funcs, fn)
+	}
+	if len(g.columns) == 0 {
+		return nil, fmt.Errorf("table %s has no columns to generate", t.Name)
+	}
+	pos := map[string]int{}
+	for j, c := range g.columns {
+		pos[c] = j
+	}
+	for _, cols := range compositeUniqueKeys(t) {
+		// skipped columns get their default value, the others have to
+		// make the combination unique
+		k := uniqueKey{seen: map[string]bool{}}
+		for _, c := range cols {
+			if j, ok := pos[c]; ok {
+				k.columns = append(k.columns, j)
+			}
+		}
+		if len(k.columns) > 0 {
+			g.keys = append(g.keys, k)
+		}
+	}
+	return g, nil
+}
+
+// nullRatio returns the fraction of NULLs of the column. Unique columns get
+// no NULLs, as a unique index allows only one.
+func nullRatio(col schema.Column, cs ColumnSpec, unique bool) (float64, error) {
+	if cs.NullRatio == nil {
+		if col.Nullable && !unique {
+			return defaultNullRatio, nil
+		}
+		return 0, nil
+	}
+	if *cs.NullRatio > 0 && !col.Nullable {
+		return 0, fmt.Errorf("null_ratio given for NOT NULL column")
+	}
+	if *cs.NullRatio > 0 && unique {
+		return 0, fmt.Errorf("null_ratio given for unique column")
+	}
+	return *cs.NullRatio, nil
+}
+
+func baseType(sqlType string) string {
+	name, _, _ := strings.Cut(sqlType, "(")
+	return name
+}
+
+// typeArgs returns the arguments of the type, e.g. [10 2] for
+// decimal(10,2). max is returned as -1.
+func typeArgs(sqlType string) []int {
+	_, args, found := strings.Cut(sqlType, "(")
+	if !found {
+		return nil
+	}
+	var res []int
+	for _, a := range strings.Split(strings.TrimSuffix(args, ")"), ",") {
+		if a == "max" {
+			res = append(res, -1)
+			continue
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(a))
+		res = append(res, n)
+	}
+	return res
+}
+
+func isIntType(sqlType string) bool {
+	switch baseType(sqlType) {
+	case "tinyint", "smallint", "int", "bigint":
+		return true
+	}
+	return false
+}
+
+func isStringType(sqlType string) bool {
+	switch baseType(sqlType) {
+	case "char", "varchar", "nchar", "nvarchar", "text", "ntext":
+		return true
+	}
+	return false
+}
+
+// maxStringLength is the length limit for varchar(max) and text columns
+const maxStringLength = 50
+
+func stringLength(sqlType string) int {
+	if args := typeArgs(sqlType); len(args) > 0 && args[0] > 0 {
+		return args[0]
+	}
+	return maxStringLength
+}
+
+// uniqueWordFunc appends the row number to a word, so that the values are
+// unique beyond the size of the word list. The word is shortened to fit the
+// column length.
+func uniqueWordFunc(rnd *rand.Rand, col schema.Column) valueFunc {
+	n := stringLength(col.Type)
+	return func(row int64) (any, error) {
+		suffix := strconv.FormatInt(row+1, 10)
+		if len(suffix) > n {
+			return nil, fmt.Errorf("row number %s does not fit %s", suffix, col.Type)
+		}
+		w := pick(rnd, words)
+		if len(w)+len(suffix) > n {
+			w = w[:n-len(suffix)]
+		}
+		return w + suffix, nil
+	}
+}
+
+func sampleFunc(rnd *rand.Rand, col schema.Column, keys []any) (valueFunc, error) {
+	if len(keys) == 0 {
+		if !col.Nullable {
+			return nil, fmt.Errorf("the referenced table is empty")
+		}
+		return func(int64) (any, error) { return nil, nil }, nil
+	}
+	return func(int64) (any, error) { return keys[rnd.Intn(len(keys))], nil }, nil
+}
+
+// columnFunc returns the generator of a column, defined by the spec or
+// derived from the column type.
+func columnFunc(rnd *rand.Rand, col schema.Column, cs ColumnSpec) (valueFunc, error) {
+	if len(cs.Values) > 0 {
+		return enumFunc(rnd, cs)
+	}
+	gen := cs.Generator
+	if gen == "" {
+		gen = defaultGenerator(col.Type)
+	}
+	switch gen {
+	case "sequence":
+		start := int64(1)
+		if cs.Min != nil {
+			start = int64(*cs.Min)
+		}
+		return func(row int64) (any, error) { return start + row, nil }, nil
+	case "int", "float":
+		lo, hi := numberRange(col.Type)
+		if cs.Min != nil {
+			lo = *cs.Min
+		}
+		if cs.Max != nil {
+			hi = *cs.Max
+		}
+		if lo > hi {
+			return nil, fmt.Errorf("min %g is greater than max %g", lo, hi)
+		}
+		return numberFunc(rnd, cs, lo, hi)
+	case "bool":
+		return func(int64) (any, error) { return rnd.Intn(2) == 1, nil }, nil
+	case "word":
+		return func(int64) (any, error) { return pick(rnd, words), nil }, nil
+	case "sentence":
+		return func(int64) (any, error) {
+			n := 3 + rnd.Intn(6)
+			ws := make([]string, n)
+			for i := range ws {
+				ws[i] = pick(rnd, words)
+			}
+			s := strings.Join(ws, " ")
+			return strings.ToUpper(s[:1]) + s[1:] + ".", nil
+		}, nil
+	case "name":
+		return func(int64) (any, error) { return pick(rnd, firstNames) + " " + pick(rnd, lastNames), nil }, nil
+	case "first_name":
+		return func(int64) (any, error) { return pick(rnd, firstNames), nil }, nil
+	case "last_name":
+		return func(int64) (any, error) { return pick(rnd, lastNames), nil }, nil
+	case "email":
+		return func(row int64) (any, error) {
+			return fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(pick(rnd, firstNames)), strings.ToLower(pick(rnd, lastNames)), rnd.Intn(1000)), nil
+		}, nil
+	case "city":
+		return func(int64) (any, error) { return pick(rnd, cities), nil }, nil
+	case "uuid":
+		return func(int64) (any, error) {
+			b := make([]byte, 16)
+			rnd.Read(b)
+			// version 4, variant 1
+			b[6] = b[6]&0x0f | 0x40
+			b[8] = b[8]&0x3f | 0x80
+			return b, nil
+		}, nil
+	case "bytes":
+		n := stringLength(col.Type)
+		if n > 16 {
+			n = 16
+		}
+		return func(int64) (any, error) {
+			b := make([]byte, n)
+			rnd.Read(b)
+			return b, nil
+		}, nil
+	case "date":
+		from, to := 2000, 2030
+		if cs.Min != nil {
+			from = int(*cs.Min)
+		}
+		if cs.Max != nil {
+			to = int(*cs.Max)
+		}
+		start := time.Date(from, 1, 1, 0, 0, 0, 0, time.UTC)
+		span := time.Date(to, 1, 1, 0, 0, 0, 0, time.UTC).Sub(start)
+		if span <= 0 {
+			return nil, fmt.Errorf("min %d is not before max %d", from, to)
+		}
+		return func(int64) (any, error) {
+			return start.Add(time.Duration(rnd.Int63n(int64(span)))).Truncate(time.Second), nil
+		}, nil
+	case "":
+		return nil, fmt.Errorf("type %s is not supported, use a spec or skip the column", col.Type)
+	}
+	return nil, fmt.Errorf("unknown generator %q", gen)
+}
+
+func defaultGenerator(sqlType string) string {
+	switch baseType(sqlType) {
+	case "tinyint", "smallint", "int", "bigint":
+		return "int"
+	case "decimal", "numeric", "float", "real":
+		return "float"
+	case "bit":
+		return "bool"
+	case "char", "varchar", "nchar", "nvarchar", "text", "ntext":
+		return "word"
+	case "date", "datetime", "datetime2", "smalldatetime", "datetimeoffset", "time":
+		return "date"
+	case "uniqueidentifier":
+		return "uuid"
+	case "binary", "varbinary":
+		return "bytes"
+	}
+	// e.g. money, which bulk copy does not support
+	return ""
+}
+
+// numberRange returns the default range of a numeric type.
+func numberRange(sqlType string) (float64, float64) {
+	switch baseType(sqlType) {
+	case "tinyint":
+		return 0, 255
+	case "smallint":
+		return 0, 32767
+	case "decimal", "numeric":
+		hi := 1e6
+		if args := typeArgs(sqlType); len(args) == 2 {
+			hi = math.Min(hi, math.Pow10(args[0]-args[1])-1)
+		}
+		return 0, hi
+	case "float", "real":
+		return 0, 1000
+	}
+	return 1, 1e6
+}
+
+func numberFunc(rnd *rand.Rand, cs ColumnSpec, lo, hi float64) (valueFunc, error) {
+	switch cs.Distribution {
+	case "", "uniform":
+		return func(int64) (any, error) { return lo + rnd.Float64()*(hi-lo), nil }, nil
+	case "normal":
+		mean, stddev := cs.Mean, cs.Stddev
+		if mean == 0 && stddev == 0 {
+			mean, stddev = (lo+hi)/2, (hi-lo)/6
+		}
+		return func(int64) (any, error) {
+			return math.Max(lo, math.Min(hi, rnd.NormFloat64()*stddev+mean)), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown distribution %q", cs.Distribution)
+}
+
+func enumFunc(rnd *rand.Rand, cs ColumnSpec) (valueFunc, error) {
+	if len(cs.Weights) == 0 {
+		return func(int64) (any, error) { return cs.Values[rnd.Intn(len(cs.Values))], nil }, nil
+	}
+	if len(cs.Weights) != len(cs.Values) {
+		return nil, fmt.Errorf("got %d weights for %d values", len(cs.Weights), len(cs.Values))
+	}
+	cum := make([]float64, len(cs.Weights))
+	total := 0.0
+	for i, w := range cs.Weights {
+		total += w
+		cum[i] = total
+	}
+	return func(int64) (any, error) {
+		x := rnd.Float64() * total
+		for i, c := range cum {
+			if x < c {
+				return cs.Values[i], nil
+			}
+		}
+		return cs.Values[len(cs.Values)-1], nil
+	}, nil
+}
+
+func pick(rnd *rand.Rand, list []string) string {
+	return list[rnd.Intn(len(list))]
+}
+
+// convertFunc converts the generated values to the Go types bulk copy
+// expects for the column type and enforces string lengths.
+func convertFunc(col schema.Column, fn valueFunc) valueFunc {
+	typ := baseType(col.Type)
+	return func(row int64) (any, error) {
+		v, err := fn(row)
+		if err != nil || v == nil {
+			return v, err
+		}
+		switch {
+		case isIntType(col.Type):
+			switch x := v.(type) {
+			case float64:
+				return int64(math.Round(x)), nil
+			case int:
+				return int64(x), nil
+			}
+		case typ == "decimal" || typ == "numeric":
+			scale := 0
+			if args := typeArgs(col.Type); len(args) == 2 {
+				scale = args[1]
+			}
+			switch x := v.(type) {
+			case float64:
+				return strconv.FormatFloat(x, 'f', scale, 64), nil
+			case int:
+				return int64(x), nil
+			}
+		case typ == "float" || typ == "real":
+			if x, ok := v.(int); ok {
+				return float64(x), nil
+			}
+		case isStringType(col.Type):
+			var s string
+			switch x := v.(type) {
+			case string:
+				s = x
+			case []byte:
+				s = formatUUID(x)
+			case time.Time:
+				s = x.Format(time.RFC3339)
+			default:
+				s = fmt.Sprint(x)
+			}
+			if n := stringLength(col.Type); len([]rune(s)) > n {
+				s = string([]rune(s)[:n])
+			}
+			return s, nil
+		case typ == "date":
+			if x, ok := v.(time.Time); ok {
+				return x.Truncate(24 * time.Hour), nil
+			}
+		case typ == "bit":
+			switch x := v.(type) {
+			case int:
+				return x != 0, nil
+			case float64:
+				return x != 0, nil
+			case string:
+				b, err := strconv.ParseBool(x)
+				if err != nil {
+					return nil, fmt.Errorf("invalid bit value %q", x)
+				}
+				return b, nil
+			}
+		case typ == "uniqueidentifier":
+			if x, ok := v.(string); ok {
+				var u mssql.UniqueIdentifier
+				if err := u.Scan(x); err != nil {
+					return nil, fmt.Errorf("invalid uniqueidentifier %q", x)
+				}
+				return u.Value()
+			}
+		}
+		return v, nil
+	}
+}
+
+func formatUUID(b []byte) string {
+	if len(b) != 16 {
+		return fmt.Sprintf("%x", b)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// uniqueFunc retries until fn returns a value that was not generated
+// before.
+func uniqueFunc(fn valueFunc) valueFunc {
+	seen := map[string]bool{}
+	return func(row int64) (any, error) {
+		for attempt := 0; attempt < 100; attempt++ {
+			v, err := fn(row)
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprint(v)
+			if !seen[key] {
+				seen[key] = true
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("could not generate a unique value after 100 attempts, use a spec with a larger value range")
+	}
+}
+
+func nullableFunc(rnd *rand.Rand, fn valueFunc, ratio float64) valueFunc {
+	return func(row int64) (any, error) {
+		if rnd.Float64() < ratio {
+			return nil, nil
+		}
+		return fn(row)
+	}
+}
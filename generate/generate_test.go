@@ -0,0 +1,283 @@
+package generate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwbargsten/go-mssql-load/schema"
+)
+
+func testTable() *schema.Table {
+	return &schema.Table{
+		Name: "pokemon.pokemon",
+		Columns: []schema.Column{
+			{Name: "id", Type: "int", Identity: true},
+			{Name: "code", Type: "int"},
+			{Name: "name", Type: "varchar(5)"},
+			{Name: "hp", Type: "smallint", Nullable: true},
+			{Name: "weight", Type: "decimal(5,2)"},
+			{Name: "type_id", Type: "int"},
+			{Name: "caught", Type: "date"},
+			{Name: "guid", Type: "uniqueidentifier"},
+			{Name: "version", Type: "rowversion"},
+		},
+		Indexes: []schema.Index{
+			{Name: "pk", Columns: []string{"id"}, Unique: true, Primary: true},
+			{Name: "ux_code", Columns: []string{"code"}, Unique: true},
+		},
+		ForeignKeys: []schema.ForeignKey{{Name: "fk_type", Columns: []string{"type_id"}, RefTable: "pokemon.types", RefColumns: []string{"id"}}},
+	}
+}
+
+func testOptions(seed int64) Options {
+	return Options{
+		Seed:          seed,
+		ParentKeys:    map[string][]any{"type_id": {int64(7), int64(8)}},
+		SequenceStart: map[string]int64{"code": 100},
+	}
+}
+
+func generateRows(t *testing.T, g *Generator, n int64) [][]any {
+	t.Helper()
+	var rows [][]any
+	for i := int64(0); i < n; i++ {
+		row, err := g.Row(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestGenerator(t *testing.T) {
+	g, err := New(testTable(), Spec{}, testOptions(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"code", "name", "hp", "weight", "type_id", "caught", "guid"}
+	if !reflect.DeepEqual(g.Columns(), want) {
+		t.Fatalf("columns: got %v, want %v", g.Columns(), want)
+	}
+
+	rows := generateRows(t, g, 200)
+	nulls := 0
+	for i, row := range rows {
+		if row[0] != int64(100+i) {
+			t.Errorf("code: got %v, want %d", row[0], 100+i)
+		}
+		if s := row[1].(string); len(s) > 5 || s == "" {
+			t.Errorf("name: %q does not fit varchar(5)", s)
+		}
+		if row[2] == nil {
+			nulls++
+		} else if hp := row[2].(int64); hp < 0 || hp > 32767 {
+			t.Errorf("hp: %d out of range", hp)
+		}
+		if w := row[3].(string); len(w) > 6 || !strings.Contains(w, ".") {
+			t.Errorf("weight: %q does not fit decimal(5,2)", w)
+		}
+		if k := row[4]; k != int64(7) && k != int64(8) {
+			t.Errorf("type_id: %v is not a parent key", k)
+		}
+		if d := row[5].(time.Time); d != d.Truncate(24*time.Hour) {
+			t.Errorf("caught: %v is not a date", d)
+		}
+		if b := row[6].([]byte); len(b) != 16 {
+			t.Errorf("guid: got %d bytes", len(b))
+		}
+	}
+	if nulls == 0 || nulls == len(rows) {
+		t.Errorf("hp: got %d NULLs in %d rows", nulls, len(rows))
+	}
+
+	again, _ := New(testTable(), Spec{}, testOptions(1))
+	if !reflect.DeepEqual(rows, generateRows(t, again, 200)) {
+		t.Error("the same seed generated different rows")
+	}
+	other, _ := New(testTable(), Spec{}, testOptions(2))
+	if reflect.DeepEqual(rows, generateRows(t, other, 200)) {
+		t.Error("another seed generated the same rows")
+	}
+}
+
+func TestGeneratorSpec(t *testing.T) {
+	spec, err := ParseSpec([]byte(`
+columns:
+  name: {values: [fire, water], weights: [0, 1]}
+  hp: {min: 10, max: 20, distribution: normal, null_ratio: 0}
+  code: {generator: int, min: 1, max: 1000}
+  guid: {skip: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(testTable(), spec, testOptions(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(g.Columns(), ","); got != "code,name,hp,weight,type_id,caught" {
+		t.Fatalf("columns: got %s", got)
+	}
+	seen := map[any]bool{}
+	for _, row := range generateRows(t, g, 100) {
+		if seen[row[0]] {
+			t.Errorf("code: %v is not unique", row[0])
+		}
+		seen[row[0]] = true
+		if row[1] != "water" {
+			t.Errorf("name: got %v, want water", row[1])
+		}
+		if hp := row[2].(int64); hp < 10 || hp > 20 {
+			t.Errorf("hp: %d out of range", hp)
+		}
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		opts Options
+		want string
+	}{
+		{spec: `columns: {nope: {skip: true}}`, want: "unknown column nope"},
+		{spec: `columns: {code: {null_ratio: 0.5}}`, want: "NOT NULL"},
+		{spec: `columns: {name: {generator: phone}}`, want: `unknown generator "phone"`},
+		{spec: `columns: {name: {values: [a, b], weights: [1]}}`, want: "1 weights for 2 values"},
+		{spec: `columns: {hp: {distribution: zipf}}`, want: `unknown distribution "zipf"`},
+		{spec: `columns: {}`, opts: Options{}, want: "referenced table is empty"},
+	} {
+		spec, err := ParseSpec([]byte(tc.spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := tc.opts
+		if tc.opts.ParentKeys == nil && tc.want != "referenced table is empty" {
+			opts = testOptions(1)
+		}
+		_, err = New(testTable(), spec, opts)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.spec, err, tc.want)
+		}
+	}
+}
+
+func TestUniqueExhausted(t *testing.T) {
+	spec, _ := ParseSpec([]byte(`columns: {code: {values: [1, 2]}}`))
+	g, err := New(testTable(), spec, testOptions(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lastErr error
+	for i := int64(0); i < 3 && lastErr == nil; i++ {
+		_, lastErr = g.Row(i)
+	}
+	if lastErr == nil || !strings.Contains(lastErr.Error(), "column code: could not generate a unique value") {
+		t.Errorf("got error %v", lastErr)
+	}
+}
+
+func TestGeneratorUnique(t *testing.T) {
+	table := &schema.Table{
+		Name: "pokemon.moves",
+		Columns: []schema.Column{
+			{Name: "pokemon", Type: "int"},
+			{Name: "slot", Type: "tinyint"},
+			{Name: "code", Type: "varchar(10)", Nullable: true},
+		},
+		Indexes: []schema.Index{
+			{Name: "pk", Columns: []string{"pokemon", "slot"}, Unique: true, Primary: true},
+			{Name: "ux_code", Columns: []string{"code"}, Unique: true},
+		},
+	}
+	spec, _ := ParseSpec([]byte(`
+columns:
+  pokemon: {values: [1, 2, 3]}
+  slot: {min: 1, max: 4}
+`))
+	g, err := New(table, spec, Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[[2]any]bool{}
+	for _, row := range generateRows(t, g, 12) {
+		k := [2]any{row[0], row[1]}
+		if seen[k] {
+			t.Errorf("(pokemon, slot): %v is not unique", k)
+		}
+		seen[k] = true
+		if row[2] == nil {
+			t.Error("code: got NULL in unique column")
+		}
+	}
+	if _, err := g.Row(12); err == nil || !strings.Contains(err.Error(), "columns pokemon, slot: could not generate a unique combination") {
+		t.Errorf("got error %v", err)
+	}
+
+	spec, _ = ParseSpec([]byte(`columns: {code: {null_ratio: 0.5}}`))
+	if _, err := New(table, spec, Options{}); err == nil || !strings.Contains(err.Error(), "null_ratio given for unique column") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestGeneratorUniqueStrings(t *testing.T) {
+	table := &schema.Table{
+		Name: "pokemon.trainers",
+		Columns: []schema.Column{
+			{Name: "login", Type: "varchar(6)"},
+		},
+		Indexes: []schema.Index{{Name: "pk", Columns: []string{"login"}, Unique: true, Primary: true}},
+	}
+	g, err := New(table, Spec{}, Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := int64(len(words) * 3)
+	seen := map[any]bool{}
+	for _, row := range generateRows(t, g, n) {
+		if s := row[0].(string); len(s) > 6 {
+			t.Errorf("login: %q does not fit varchar(6)", s)
+		}
+		if seen[row[0]] {
+			t.Errorf("login: %v is not unique", row[0])
+		}
+		seen[row[0]] = true
+	}
+}
+
+func TestGeneratorConvertSpecValues(t *testing.T) {
+	table := &schema.Table{
+		Name: "pokemon.flags",
+		Columns: []schema.Column{
+			{Name: "legendary", Type: "bit"},
+			{Name: "shiny", Type: "bit"},
+			{Name: "guid", Type: "uniqueidentifier"},
+		},
+	}
+	spec, _ := ParseSpec([]byte(`
+columns:
+  legendary: {values: [1]}
+  shiny: {values: ["false"]}
+  guid: {values: [6F9619FF-8B86-D011-B42D-00C04FC964FF]}
+`))
+	g, err := New(table, spec, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := g.Row(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{true, false, []byte{0xff, 0x19, 0x96, 0x6f, 0x86, 0x8b, 0x11, 0xd0, 0xb4, 0x2d, 0x00, 0xc0, 0x4f, 0xc9, 0x64, 0xff}}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("got %v, want %v", row, want)
+	}
+
+	spec, _ = ParseSpec([]byte(`columns: {legendary: {values: [maybe]}}`))
+	g, _ = New(table, spec, Options{})
+	if _, err := g.Row(0); err == nil || !strings.Contains(err.Error(), `invalid bit value "maybe"`) {
+		t.Errorf("got error %v", err)
+	}
+}
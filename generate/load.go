@@ -0,0 +1,122 @@
+package generate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/schema"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// SampleSize is the maximum number of parent keys sampled per foreign key.
+const SampleSize = 10000
+
+// Prepare reads the keys of the referenced tables and the current maximum
+// of the unique integer columns into opts, so that the generated rows
+// satisfy the foreign keys and do not collide with existing rows. Columns
+// defined in the spec are left alone.
+func Prepare(ctx context.Context, db *sql.DB, t *schema.Table, spec Spec, opts *Options) error {
+	if opts.ParentKeys == nil {
+		opts.ParentKeys = map[string][]any{}
+	}
+	if opts.SequenceStart == nil {
+		opts.SequenceStart = map[string]int64{}
+	}
+	fks := ForeignKeyColumns(t)
+	for _, col := range t.Columns {
+		if _, ok := spec.Columns[col.Name]; ok {
+			continue
+		}
+		if ref, ok := fks[col.Name]; ok {
+			query := fmt.Sprintf("SELECT DISTINCT TOP (%d) %s FROM %s WHERE %[2]s IS NOT NULL ORDER BY %[2]s",
+				SampleSize, mssqlload.QuoteName(ref[1]), mssqlload.QuoteTableName(ref[0]))
+			keys, err := queryColumn(ctx, db, query)
+			if err != nil {
+				return fmt.Errorf("could not sample keys of %s: %w", ref[0], err)
+			}
+			opts.ParentKeys[col.Name] = keys
+		}
+	}
+	for name := range UniqueColumns(t) {
+		if _, ok := spec.Columns[name]; ok {
+			continue
+		}
+		col := column(t, name)
+		if col == nil || !isIntType(col.Type) || col.Identity {
+			continue
+		}
+		query := fmt.Sprintf("SELECT ISNULL(MAX(CAST(%s AS bigint)), 0) + 1 FROM %s",
+			mssqlload.QuoteName(name), mssqlload.QuoteTableName(t.Name))
+		var start int64
+		if err := db.QueryRowContext(ctx, query).Scan(&start); err != nil {
+			return mssqlload.ContextError(ctx, err)
+		}
+		opts.SequenceStart[name] = start
+	}
+	return nil
+}
+
+func column(t *schema.Table, name string) *schema.Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+func queryColumn(ctx context.Context, db *sql.DB, query string) ([]any, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, mssqlload.ContextError(ctx, err)
+	}
+	defer rows.Close()
+	var res []any
+	for rows.Next() {
+		var v any
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, mssqlload.ContextError(ctx, rows.Err())
+}
+
+// Load inserts n generated rows into the table with bulk copy, in a single
+// transaction.
+func Load(ctx context.Context, db *sql.DB, table string, g *Generator, n int64) (int64, error) {
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, mssqlload.ContextError(ctx, err)
+	}
+	defer txn.Rollback()
+	stmt, err := txn.PrepareContext(ctx, mssql.CopyIn(mssqlload.QuoteTableName(table), mssql.BulkOptions{}, g.Columns()...))
+	if err != nil {
+		return 0, mssqlload.ContextError(ctx, err)
+	}
+	defer stmt.Close()
+
+	for i := int64(0); i < n; i++ {
+		row, err := g.Row(i)
+		if err != nil {
+			return 0, fmt.Errorf("row %d: %w", i+1, err)
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return 0, fmt.Errorf("could not exec sql: %w", mssqlload.ContextError(ctx, err))
+		}
+	}
+
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, mssqlload.ContextError(ctx, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, mssqlload.ContextError(ctx, err)
+	}
+	if err := txn.Commit(); err != nil {
+		return 0, mssqlload.ContextError(ctx, err)
+	}
+	return result.RowsAffected()
+}
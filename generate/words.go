@@ -0,0 +1,33 @@
+package generate
+
+var words = []string{
+	"alpha", "amber", "anchor", "apple", "arrow", "autumn", "badge", "bamboo", "basil", "beacon",
+	"berry", "blaze", "bloom", "breeze", "brook", "cactus", "canyon", "cedar", "cherry", "cliff",
+	"cloud", "clover", "comet", "coral", "crystal", "dawn", "delta", "desert", "dune", "eagle",
+	"ember", "falcon", "fern", "flame", "forest", "frost", "garden", "glacier", "granite", "harbor",
+	"hazel", "horizon", "island", "ivory", "jade", "jasmine", "lagoon", "lantern", "lava", "lemon",
+	"lotus", "maple", "meadow", "meteor", "mist", "moss", "nebula", "nectar", "oasis", "ocean",
+	"olive", "orchid", "pebble", "pine", "planet", "prairie", "quartz", "rain", "raven", "reef",
+	"ridge", "river", "sage", "sand", "shadow", "shell", "sky", "snow", "spark", "spruce",
+	"storm", "stone", "summit", "sun", "thunder", "tide", "timber", "topaz", "valley", "velvet",
+	"violet", "volcano", "wave", "willow", "wind", "winter", "wolf", "zephyr",
+}
+
+var firstNames = []string{
+	"Ada", "Alan", "Alice", "Amir", "Ana", "Ben", "Bo", "Carla", "Chen", "Dana",
+	"David", "Elena", "Emma", "Finn", "Grace", "Hana", "Ivan", "Jonas", "Julia", "Kai",
+	"Lara", "Leo", "Lina", "Luca", "Maya", "Mia", "Noah", "Nora", "Omar", "Priya",
+	"Rosa", "Sam", "Sara", "Theo", "Uma", "Vera", "Wim", "Yara", "Yusuf", "Zoe",
+}
+
+var lastNames = []string{
+	"Andersen", "Bakker", "Costa", "Dubois", "Evans", "Fischer", "Garcia", "Hansen", "Ito", "Jansen",
+	"Kim", "Larsen", "Martin", "Nowak", "Okafor", "Petrov", "Quinn", "Rossi", "Silva", "Tanaka",
+	"Visser", "Wagner", "Walsh", "Yilmaz", "Zhang",
+}
+
+var cities = []string{
+	"Amsterdam", "Berlin", "Buenos Aires", "Cairo", "Cape Town", "Chicago", "Delhi", "Dublin",
+	"Helsinki", "Istanbul", "Jakarta", "Lagos", "Lima", "Lisbon", "London", "Madrid", "Montreal",
+	"Mumbai", "Nairobi", "Oslo", "Paris", "Rome", "Seoul", "Sydney", "Tokyo", "Toronto", "Vienna",
+}
@@ -0,0 +1,239 @@
+// Package lint checks T-SQL scripts offline for problems that batch.Split
+// does not detect and that otherwise only surface on the server.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Off     Severity = "off"
+)
+
+// Rule is a check with its default severity.
+type Rule struct {
+	Name        string
+	Severity    Severity
+	Description string
+}
+
+// Rules lists the available rules.
+var Rules = []Rule{
+	{"unterminated", Error, "unterminated string, quoted identifier or block comment"},
+	{"go-in-string", Warning, "GO line inside a string or block comment, sqlcmd and SSMS split the batch there"},
+	{"go-separator", Error, "line starting with GO followed by other text, e.g. GOTO, which is split as batch separator"},
+	{"create-first-in-batch", Error, "CREATE/ALTER VIEW, PROCEDURE, FUNCTION or TRIGGER and CREATE SCHEMA not first in the batch"},
+	{"drop-if-exists", Warning, "DROP without IF EXISTS, which fails if the object does not exist"},
+	{"use-database", Warning, "USE changing the database in the middle of the script"},
+	{"sqlcmd-directive", Error, "sqlcmd directives (:r, :setvar, !!, ...) and $(var) variables, which are not supported"},
+}
+
+// Config overrides the severities of rules, by rule name.
+type Config map[string]Severity
+
+// ParseConfig parses rule severities, e.g. from the config file or the
+// command line.
+func ParseConfig(rules map[string]string) (Config, error) {
+	cfg := Config{}
+	for name, v := range rules {
+		if !isRule(name) {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		s := Severity(strings.ToLower(v))
+		switch s {
+		case Error, Warning, Off:
+		default:
+			return nil, fmt.Errorf("invalid severity %q of rule %s, expected error, warning or off", v, name)
+		}
+		cfg[name] = s
+	}
+	return cfg, nil
+}
+
+func isRule(name string) bool {
+	for _, r := range Rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Severity returns the severity of rule.
+func (c Config) Severity(rule string) Severity {
+	if s, ok := c[rule]; ok {
+		return s
+	}
+	for _, r := range Rules {
+		if r.Name == rule {
+			return r.Severity
+		}
+	}
+	return Off
+}
+
+// Finding is a problem found in a script. Line and Column are 1-based,
+// Column counts characters.
+type Finding struct {
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	pos := fmt.Sprintf("%d:%d", f.Line, f.Column)
+	if f.File != "" {
+		pos = f.File + ":" + pos
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", pos, f.Severity, f.Message, f.Rule)
+}
+
+// Lint checks script and returns the findings of the enabled rules, ordered
+// by position.
+func Lint(script string, cfg Config) []Finding {
+	toks, findings := scan(script)
+	findings = append(findings, checkTokens(toks)...)
+
+	var res []Finding
+	for _, f := range findings {
+		f.Severity = cfg.Severity(f.Rule)
+		if f.Severity != Off {
+			res = append(res, f)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Line != res[j].Line {
+			return res[i].Line < res[j].Line
+		}
+		return res[i].Column < res[j].Column
+	})
+	return res
+}
+
+var (
+	firstInBatchKinds = map[string]bool{"VIEW": true, "PROC": true, "PROCEDURE": true, "FUNCTION": true, "TRIGGER": true}
+	// DROP LOGIN, for example, does not support IF EXISTS
+	dropKinds = map[string]bool{
+		"TABLE": true, "VIEW": true, "PROC": true, "PROCEDURE": true, "FUNCTION": true, "TRIGGER": true,
+		"SCHEMA": true, "INDEX": true, "TYPE": true, "SYNONYM": true, "SEQUENCE": true, "DATABASE": true,
+		"USER": true, "ROLE": true,
+	}
+)
+
+// checkTokens applies the rules that work on the statements.
+func checkTokens(toks []token) []Finding {
+	var res []Finding
+	add := func(t token, rule string, format string, args ...any) {
+		res = append(res, Finding{Line: t.line, Column: t.col, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	word := func(i int) string {
+		if i < len(toks) && toks[i].kind == wordToken && toks[i].batch == toks[i-1].batch {
+			return toks[i].text
+		}
+		return ""
+	}
+	// first reports whether toks[i] starts its batch, ignoring semicolons
+	first := func(i int) bool {
+		for k := i - 1; k >= 0 && toks[k].batch == toks[i].batch; k-- {
+			if !toks[k].semicolon() {
+				return false
+			}
+		}
+		return true
+	}
+
+	for i, t := range toks {
+		if t.kind != wordToken {
+			continue
+		}
+		switch t.text {
+		case "CREATE", "ALTER":
+			if t.text == "ALTER" && i >= 2 && toks[i-1].text == "OR" && toks[i-2].text == "CREATE" {
+				continue
+			}
+			if permission(toks, i) {
+				continue
+			}
+			j := i + 1
+			if t.text == "CREATE" && word(j) == "OR" && word(j+1) == "ALTER" {
+				j += 2
+			}
+			kind := word(j)
+			if (firstInBatchKinds[kind] || (kind == "SCHEMA" && t.text == "CREATE")) && !first(i) {
+				add(t, "create-first-in-batch", "%s %s must be the first statement in a batch, add GO before it", t.text, kind)
+			}
+		case "DROP":
+			kind := word(i + 1)
+			if !dropKinds[kind] || (word(i+2) == "IF" && word(i+3) == "EXISTS") || guarded(toks, i) {
+				continue
+			}
+			add(t, "drop-if-exists", "DROP %s without IF EXISTS fails if the object does not exist", kind)
+		case "USE":
+			if i+1 >= len(toks) || (toks[i+1].kind != wordToken && toks[i+1].kind != identToken) {
+				continue
+			}
+			// OPTION (USE HINT(...)), OPTION (USE PLAN ...)
+			if next := word(i + 1); next == "HINT" || next == "PLAN" {
+				continue
+			}
+			if !startsScript(toks, i) {
+				add(t, "use-database", "USE %s changes the database in the middle of the script", toks[i+1].raw)
+			}
+		}
+	}
+	return res
+}
+
+// guarded reports whether the DROP at toks[i] is preceded by an IF in the
+// same statement, e.g. IF OBJECT_ID('t') IS NOT NULL DROP TABLE t.
+func guarded(toks []token, i int) bool {
+	for k := i - 1; k >= 0 && toks[k].batch == toks[i].batch; k-- {
+		switch t := toks[k]; {
+		case t.semicolon(), t.kind == wordToken && t.text == "END":
+			return false
+		case t.kind == wordToken && t.text == "IF":
+			return true
+		}
+	}
+	return false
+}
+
+// permission reports whether the CREATE or ALTER at toks[i] is a permission
+// of a GRANT, DENY or REVOKE, e.g. GRANT SELECT, CREATE VIEW TO r. Only the
+// permission list is searched, which ends at TO, ON or FROM.
+func permission(toks []token, i int) bool {
+	for k := i - 1; k >= 0 && toks[k].batch == toks[i].batch; k-- {
+		switch t := toks[k]; {
+		case t.kind == wordToken && (t.text == "GRANT" || t.text == "DENY" || t.text == "REVOKE"):
+			return true
+		case t.kind == punctToken && t.text == ",":
+		case t.kind != wordToken, t.text == "TO", t.text == "ON", t.text == "FROM":
+			return false
+		}
+	}
+	return false
+}
+
+// startsScript reports whether only USE statements precede toks[i].
+func startsScript(toks []token, i int) bool {
+	for k := 0; k < i; k++ {
+		t := toks[k]
+		switch {
+		case t.semicolon():
+		case t.kind == wordToken && t.text == "USE":
+			k++
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+)
+
+type result struct {
+	Line int
+	Rule string
+}
+
+func lintRules(script string, cfg Config) []result {
+	var res []result
+	for _, f := range Lint(script, cfg) {
+		res = append(res, result{f.Line, f.Rule})
+	}
+	return res
+}
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name     string
+		script   string
+		expected []result
+	}{
+		{"clean", "USE pokedb;\nGO\nDROP TABLE IF EXISTS t;\nGO\nCREATE VIEW v AS SELECT 1 AS x\nGO 2\n", nil},
+		{"unterminated string", "SELECT 'abc\nGO\n", []result{{1, "unterminated"}, {2, "go-in-string"}}},
+		{"unterminated comment", "/* a /* nested */\nSELECT 1\n", []result{{1, "unterminated"}}},
+		{"unterminated identifier", "SELECT [a\n", []result{{1, "unterminated"}}},
+		{"go in comment", "/*\nGO\n*/\nSELECT 'it''s'\n", []result{{2, "go-in-string"}}},
+		{"go separator", "SELECT 1\nGOTO done\n", []result{{2, "go-separator"}}},
+		{"create not first", "SELECT 1;\nCREATE OR ALTER PROCEDURE p AS SELECT 1\n", []result{{2, "create-first-in-batch"}}},
+		{"create schema not first", "DROP TABLE IF EXISTS t\nCREATE SCHEMA s\n", []result{{2, "create-first-in-batch"}}},
+		{"grant", "SELECT 1\nGRANT CREATE VIEW TO r\nDENY SELECT, CREATE PROCEDURE TO r\nREVOKE ALTER ON SCHEMA::s FROM r\nREVOKE GRANT OPTION FOR CREATE TABLE FROM r\n", nil},
+		{"create after grant", "GRANT SELECT ON t TO r\nCREATE VIEW v AS SELECT 1 AS x\n", []result{{2, "create-first-in-batch"}}},
+		{"create table", "SELECT 1\nCREATE TABLE t (id int)\nALTER SCHEMA s TRANSFER t\n", nil},
+		{"drop", "DROP TABLE t\nDROP VIEW [v]\n", []result{{1, "drop-if-exists"}, {2, "drop-if-exists"}}},
+		{"drop guarded", "IF OBJECT_ID('t') IS NOT NULL DROP TABLE t\nALTER TABLE t DROP COLUMN c\n", nil},
+		{"drop not guarded", "IF @x = 1 BEGIN SELECT 1 END\nDROP TABLE t\n", []result{{2, "drop-if-exists"}}},
+		{"use", "SELECT 1\nGO\nUSE other\nSELECT 1 OPTION (USE HINT ('x'))\n", []result{{3, "use-database"}}},
+		{"sqlcmd", ":setvar db pokedb\nSELECT * FROM $(db).dbo.t\n!!dir\n", []result{{1, "sqlcmd-directive"}, {2, "sqlcmd-directive"}, {3, "sqlcmd-directive"}}},
+		{"strings and comments", "SELECT 'DROP TABLE t', N'$(x)' -- DROP TABLE t\n/* USE x */\n", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := lintRules(c.script, nil); !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("expected %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	cfg, err := ParseConfig(map[string]string{"drop-if-exists": "off", "use-database": "ERROR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := Lint("SELECT 1\nDROP TABLE t\nUSE x\n", cfg)
+	if len(findings) != 1 || findings[0].Rule != "use-database" || findings[0].Severity != Error {
+		t.Errorf("unexpected findings %v", findings)
+	}
+	if _, err := ParseConfig(map[string]string{"nope": "off"}); err == nil {
+		t.Error("expected error for unknown rule")
+	}
+	if _, err := ParseConfig(map[string]string{"unterminated": "fatal"}); err == nil {
+		t.Error("expected error for invalid severity")
+	}
+}
+
+func TestFindingString(t *testing.T) {
+	f := Finding{File: "init.sql", Line: 3, Column: 1, Rule: "drop-if-exists", Severity: Warning, Message: "DROP TABLE without IF EXISTS"}
+	expected := "init.sql:3:1: warning: DROP TABLE without IF EXISTS (drop-if-exists)"
+	if f.String() != expected {
+		t.Errorf("expected %q, got %q", expected, f.String())
+	}
+}
@@ -0,0 +1,212 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	// identToken is a quoted identifier, [name] or "name"
+	identToken
+	stringToken
+	numberToken
+	punctToken
+)
+
+// token is a token of the code, outside of comments. The text of words is
+// upper case, raw is the text as written. batch is the index of the batch
+// of the token.
+type token struct {
+	kind      tokenKind
+	text, raw string
+	line, col int
+	batch     int
+}
+
+func (t token) semicolon() bool {
+	return t.kind == punctToken && t.text == ";"
+}
+
+type scanState int
+
+const (
+	inCode scanState = iota
+	inComment
+	inString
+	inIdent
+)
+
+var stateNames = map[scanState]string{inComment: "block comment", inString: "string", inIdent: "quoted identifier"}
+
+// goLine matches a batch separator, like "GO", "go 5" or "GO -- comment".
+var goLine = regexp.MustCompile(`(?i)^GO(\s+\d+)?\s*(--.*)?$`)
+
+type scanner struct {
+	toks     []token
+	findings []Finding
+
+	state scanState
+	// depth of nested block comments
+	depth int
+	// closing quote of the quoted identifier
+	closing rune
+	// start of the current string, comment or quoted identifier
+	startLine, startCol int
+	text                strings.Builder
+	batch               int
+}
+
+// scan splits script into tokens and batches like batch.Split and reports
+// the problems on the level of lines and characters.
+func scan(script string) ([]token, []Finding) {
+	s := &scanner{}
+	for i, line := range strings.Split(script, "\n") {
+		s.scanLine(i+1, strings.TrimSuffix(line, "\r"))
+	}
+	if s.state != inCode {
+		s.finding(s.startLine, s.startCol, "unterminated", "unterminated %s", stateNames[s.state])
+	}
+	return s.toks, s.findings
+}
+
+func (s *scanner) finding(line, col int, rule string, format string, args ...any) {
+	s.findings = append(s.findings, Finding{Line: line, Column: col, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+func (s *scanner) token(kind tokenKind, text string, line, col int) {
+	raw := text
+	if kind == wordToken {
+		text = strings.ToUpper(raw)
+	}
+	s.toks = append(s.toks, token{kind: kind, text: text, raw: raw, line: line, col: col, batch: s.batch})
+}
+
+func (s *scanner) scanLine(ln int, line string) {
+	trimmed := strings.TrimSpace(line)
+	indent := 1 + len([]rune(line)) - len([]rune(strings.TrimLeftFunc(line, unicode.IsSpace)))
+	switch {
+	case s.state == inCode && goLine.MatchString(trimmed):
+		s.batch++
+		return
+	case s.state == inCode && len(trimmed) >= 2 && strings.EqualFold(trimmed[:2], "GO"):
+		s.finding(ln, indent, "go-separator", "%q is split as batch separator GO, GO must be alone on its line", trimmed)
+		s.batch++
+		return
+	case s.state == inCode && (strings.HasPrefix(trimmed, "!!") || len(trimmed) >= 2 && trimmed[0] == ':' && unicode.IsLetter(rune(trimmed[1]))):
+		s.finding(ln, indent, "sqlcmd-directive", "sqlcmd directive %s is not supported", strings.Fields(trimmed)[0])
+		return
+	case s.state != inCode && goLine.MatchString(trimmed):
+		s.finding(ln, indent, "go-in-string", "GO inside a %s: the batch is kept, but sqlcmd and SSMS split it here", stateNames[s.state])
+	}
+
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		c, next := rs[i], rune(0)
+		if i+1 < len(rs) {
+			next = rs[i+1]
+		}
+		col := i + 1
+		switch s.state {
+		case inCode:
+			switch {
+			case c == '-' && next == '-':
+				return
+			case c == '/' && next == '*':
+				s.begin(inComment, ln, col)
+				s.depth = 1
+				i++
+			case c == '\'':
+				s.begin(inString, ln, col)
+			case (c == 'N' || c == 'n') && next == '\'':
+				s.begin(inString, ln, col)
+				i++
+			case c == '[' || c == '"':
+				s.begin(inIdent, ln, col)
+				s.closing = ']'
+				if c == '"' {
+					s.closing = '"'
+				}
+			case c == '$' && next == '(':
+				end := strings.IndexRune(string(rs[i:]), ')')
+				name := string(rs[i:])
+				if end >= 0 {
+					name = name[:end+1]
+				}
+				s.finding(ln, col, "sqlcmd-directive", "sqlcmd variable %s is not supported", name)
+				i++
+			case unicode.IsLetter(c) || c == '_' || c == '@' || c == '#':
+				j := i + 1
+				for j < len(rs) && isWordChar(rs[j]) {
+					j++
+				}
+				s.token(wordToken, string(rs[i:j]), ln, col)
+				i = j - 1
+			case unicode.IsDigit(c):
+				j := i + 1
+				for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+					j++
+				}
+				s.token(numberToken, string(rs[i:j]), ln, col)
+				i = j - 1
+			case unicode.IsSpace(c):
+			default:
+				s.token(punctToken, string(c), ln, col)
+			}
+		case inComment:
+			switch {
+			case c == '/' && next == '*':
+				s.depth++
+				i++
+			case c == '*' && next == '/':
+				s.depth--
+				i++
+				if s.depth == 0 {
+					s.state = inCode
+				}
+			}
+		case inString:
+			switch {
+			case c == '\'' && next == '\'':
+				s.text.WriteRune(c)
+				i++
+			case c == '\'':
+				s.end(stringToken)
+			default:
+				s.text.WriteRune(c)
+			}
+		case inIdent:
+			switch {
+			case c == s.closing && next == s.closing:
+				s.text.WriteRune(c)
+				i++
+			case c == s.closing:
+				s.end(identToken)
+			default:
+				s.text.WriteRune(c)
+			}
+		}
+	}
+	if s.state == inString || s.state == inIdent {
+		s.text.WriteRune('\n')
+	}
+}
+
+func (s *scanner) begin(state scanState, line, col int) {
+	s.state = state
+	s.startLine, s.startCol = line, col
+	s.text.Reset()
+}
+
+func (s *scanner) end(kind tokenKind) {
+	s.token(kind, s.text.String(), s.startLine, s.startCol)
+	s.state = inCode
+}
+
+func isWordChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '@' || c == '#' || c == '$'
+}
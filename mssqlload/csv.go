@@ -0,0 +1,418 @@
+package mssqlload
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"go.uber.org/zap"
+)
+
+type ColTypes struct {
+	byName map[string]string
+	byPos  []string
+}
+
+// ParseColTypes parses a JSON types definition, either as list (by column
+// position) or as dict (by column name).
+func ParseColTypes(typesB []byte) (ColTypes, error) {
+	// https://stackoverflow.com/questions/55014001/check-if-json-is-object-or-array
+	typesB = bytes.TrimLeft(typesB, " \t\r\n")
+
+	var colTypes ColTypes
+	isArray := len(typesB) > 0 && typesB[0] == '['
+	isObject := len(typesB) > 0 && typesB[0] == '{'
+
+	if isArray {
+		if err := json.Unmarshal(typesB, &colTypes.byPos); err != nil {
+			return ColTypes{}, err
+		}
+	} else if isObject {
+		if err := json.Unmarshal(typesB, &colTypes.byName); err != nil {
+			return ColTypes{}, err
+		}
+	} else {
+		return ColTypes{}, errors.New("JSON type file doesn't seem to have an array or dict structure")
+	}
+	return colTypes, nil
+}
+
+func LoadColTypes(f string) (ColTypes, error) {
+	typesB, err := os.ReadFile(f)
+	if err != nil {
+		return ColTypes{}, err
+	}
+	return ParseColTypes(typesB)
+}
+
+func (ct ColTypes) FindByName(name string) (string, bool) {
+	if ct.byName == nil {
+		return "", false
+	}
+	v, ok := ct.byName[name]
+	return v, ok
+}
+func (ct ColTypes) FindByIdx(idx int) (string, bool) {
+	if ct.byPos == nil {
+		return "", false
+	}
+	if idx >= len(ct.byPos) || idx < 0 {
+		return "", false
+	}
+
+	v := ct.byPos[idx]
+	return v, true
+}
+
+func (ct ColTypes) Find(idx int, name string) (string, bool) {
+	if t, found := ct.FindByIdx(idx); found {
+		return t, true
+	}
+	if t, found := ct.FindByName(name); found {
+		return t, true
+	}
+	return "", false
+}
+
+// LoaderType maps a SQL Server data type, e.g. "varchar(255)", to the column
+// type of the CSV loader: int, float, bool or string.
+func LoaderType(sqlType string) string {
+	name, _, _ := strings.Cut(strings.ToLower(sqlType), "(")
+	switch strings.TrimSpace(name) {
+	case "int", "bigint", "smallint", "tinyint":
+		return "int"
+	case "float", "real", "decimal", "numeric", "money", "smallmoney":
+		return "float"
+	case "bit":
+		return "bool"
+	}
+	return "string"
+}
+
+type Header struct {
+	Colnames []string
+	Colopt   []bool
+	Ncols    int
+	Types    []string
+	Parsers  []func(string) (any, error)
+}
+
+func parseInt(v string) (any, error)   { return strconv.ParseInt(v, 10, 64) }
+func parseFloat(v string) (any, error) { return strconv.ParseFloat(v, 64) }
+
+func parseBool(v string) (any, error) {
+	// this should account for TRUE, true, T, t
+	if strings.HasPrefix(strings.ToLower(v), "t") {
+		return true, nil
+	}
+	// this should account for YES, yes, Y, y
+	if strings.HasPrefix(strings.ToLower(v), "y") {
+		return true, nil
+	}
+	// so, perhaps we have a number (0/1)
+	vParsed, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return vParsed > 0, nil
+}
+func parseString(v string) (any, error) { return v, nil }
+
+// ParseHeader parses the CSV header. Column types can be specified as part of
+// the column name (name::type[!]) or via colTypes, which takes precedence.
+func ParseHeader(header []string, colTypes ColTypes, log *zap.SugaredLogger) Header {
+	log = logger(log)
+	ncols := len(header)
+
+	types := make([]string, ncols)
+	parsers := make([]func(string) (any, error), ncols)
+	colnames := make([]string, ncols)
+	colopt := make([]bool, ncols)
+	for colidx, col := range header {
+		res := strings.SplitN(col, "::", 2)
+		var colname, coltype string
+		if len(res) == 2 {
+			colname, coltype = res[0], res[1]
+		} else {
+			// no type info in column header, just assume string
+			colname = col
+			coltype = ""
+		}
+
+		if ct, found := colTypes.Find(colidx, colname); found {
+			coltype = ct
+		}
+
+		colopt[colidx] = false
+		if len(coltype) > 0 && coltype[len(coltype)-1:] == "!" {
+			colopt[colidx] = true
+			coltype = coltype[:len(coltype)-1]
+		}
+
+		colnames[colidx] = colname
+		switch coltype {
+		case "int":
+			parsers[colidx] = parseInt
+			types[colidx] = "int"
+		case "float":
+			parsers[colidx] = parseFloat
+			types[colidx] = "float"
+		case "bool":
+			parsers[colidx] = parseBool
+			types[colidx] = "bool"
+		case "string":
+			parsers[colidx] = parseString
+			types[colidx] = "string"
+		default:
+			log.Infow("No column type specified, using string.",
+				"name", colname,
+				"idx", colidx,
+				"type", coltype,
+			)
+			parsers[colidx] = parseString
+			types[colidx] = "string"
+		}
+	}
+
+	return Header{
+		Colopt:   colopt,
+		Colnames: colnames,
+		Parsers:  parsers,
+		Ncols:    ncols,
+		Types:    types,
+	}
+}
+
+// ParseRow converts the string values of a CSV record into the column types
+// of the header.
+func ParseRow(header Header, row []string, nullstr string) ([]any, error) {
+	if len(row) != header.Ncols {
+		return nil, fmt.Errorf("expected %d columns, got %d", header.Ncols, len(row))
+	}
+
+	parsedRow := make([]any, header.Ncols)
+
+	for idx, v := range row {
+		if header.Colopt[idx] && v == nullstr {
+			parsedRow[idx] = nil
+			continue
+		}
+		res, err := header.Parsers[idx](v)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse column %s: %w", header.Colnames[idx], err)
+		}
+		parsedRow[idx] = res
+
+	}
+	return parsedRow, nil
+}
+
+type CSVOptions struct {
+	// NullString is the value that is inferred as NULL in nullable columns.
+	NullString string
+	// Separator defaults to ','.
+	Separator rune
+	ColTypes  ColTypes
+	// Mode is one of ModeAppend (default), ModeTruncate, ModeReplace or
+	// ModeUpsert.
+	Mode string
+	// Key are the columns ModeUpsert matches rows by.
+	Key []string
+	// DeleteMissing deletes rows that are not in the CSV data in ModeUpsert.
+	DeleteMissing bool
+	// KeepIdentity loads the values of the identity column instead of
+	// letting the server generate them, and reseeds the identity afterwards.
+	// The rows are loaded via a staging table with IDENTITY_INSERT.
+	KeepIdentity bool
+	// Progress is called every ProgressRows rows with the number of rows
+	// read so far.
+	Progress func(rows int64)
+	Log      *zap.SugaredLogger
+}
+
+// ProgressRows is the interval of CSVOptions.Progress.
+const ProgressRows = 1000
+
+type CSVResult struct {
+	Header Header
+	// Rows is the number of rows read from the CSV data.
+	Rows     int64
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+	// ParseTime is the time spent reading and parsing the CSV data,
+	// ServerTime the time spent in the driver and on the server.
+	ParseTime  time.Duration
+	ServerTime time.Duration
+}
+
+// LoadCSV bulk loads the CSV data read from r into table. The first record
+// has to be the header. All rows are loaded in one transaction, which is
+// rolled back on the first error or if ctx is cancelled. The table is
+// emptied or the rows are merged first, depending on opts.Mode. Computed and
+// rowversion columns in the CSV data are skipped.
+func LoadCSV(ctx context.Context, db *sql.DB, table string, r io.Reader, opts CSVOptions) (CSVResult, error) {
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return CSVResult{}, ContextError(ctx, err)
+	}
+	defer txn.Rollback()
+	res, err := LoadCSVTx(ctx, txn, table, r, opts)
+	if err != nil {
+		return res, err
+	}
+	if err := txn.Commit(); err != nil {
+		return res, ContextError(ctx, err)
+	}
+	return res, nil
+}
+
+// LoadCSVTx bulk loads the CSV data read from r into table in txn, like
+// LoadCSV. The transaction is neither committed nor rolled back.
+func LoadCSVTx(ctx context.Context, txn *sql.Tx, table string, r io.Reader, opts CSVOptions) (CSVResult, error) {
+	log := logger(opts.Log)
+	var res CSVResult
+	mode, err := ParseMode(opts.Mode)
+	if err != nil {
+		return res, err
+	}
+	if mode == ModeUpsert && len(opts.Key) == 0 {
+		return res, fmt.Errorf("mode upsert needs key columns")
+	}
+
+	csvReader := csv.NewReader(r)
+	if opts.Separator != 0 {
+		csvReader.Comma = opts.Separator
+	}
+
+	rawHeader, err := csvReader.Read()
+	if err != nil {
+		return res, fmt.Errorf("could not read csv: %w", err)
+	}
+	header := ParseHeader(rawHeader, opts.ColTypes, log)
+	res.Header = header
+	log.Info("columns")
+	log.Infof("%s   %-40s%-15s%s", "IDX", "NAME", "TYPE", "NULLABLE")
+	for idx, name := range header.Colnames {
+		n := ""
+		if header.Colopt[idx] {
+			n = "NULL"
+		}
+		log.Infof("[%3d] %-40s%-15s%s", idx, name, header.Types[idx], n)
+	}
+
+	tableCols, err := tableColumns(ctx, txn, table)
+	if err != nil {
+		return res, fmt.Errorf("could not read columns of %s: %w", table, err)
+	}
+	keep, identity := loadableColumns(header, tableCols)
+	loadHeader := header
+	if len(keep) < header.Ncols {
+		loadHeader = selectColumns(header, keep)
+		for _, name := range header.Colnames {
+			if !contains(loadHeader.Colnames, name) {
+				log.Infof("skipping computed or rowversion column %s", name)
+			}
+		}
+	}
+	keepIdentity := opts.KeepIdentity && identity != ""
+	if identity != "" && !opts.KeepIdentity {
+		log.Warnf("the values of identity column %s are not kept, the server generates new ones", identity)
+	}
+
+	var mergeQuery string
+	if mode == ModeUpsert {
+		mergeQuery, err = mergeStatement(table, loadHeader.Colnames, opts.Key, identity, keepIdentity, opts.DeleteMissing)
+		if err != nil {
+			return res, err
+		}
+	}
+	staging := mode == ModeUpsert || keepIdentity
+	res.Deleted, err = prepareTable(ctx, txn, table, loadHeader.Colnames, mode, staging)
+	if err != nil {
+		return res, err
+	}
+	target := table
+	if staging {
+		target = stagingTable
+	}
+	stmt, err := txn.PrepareContext(ctx, mssql.CopyIn(target, mssql.BulkOptions{}, loadHeader.Colnames...))
+	if err != nil {
+		return res, ContextError(ctx, err)
+	}
+	defer stmt.Close()
+
+	var n int64
+	for {
+		start := time.Now()
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("error reading csv: %w", err)
+		}
+		row, err := ParseRow(header, record, opts.NullString)
+		if err != nil {
+			line, _ := csvReader.FieldPos(0)
+			return res, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(keep) < header.Ncols {
+			loadRow := make([]any, len(keep))
+			for i, idx := range keep {
+				loadRow[i] = row[idx]
+			}
+			row = loadRow
+		}
+		parsed := time.Now()
+		res.ParseTime += parsed.Sub(start)
+
+		if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			return res, fmt.Errorf("could not exec sql: %w", ContextError(ctx, err))
+		}
+		res.ServerTime += time.Since(parsed)
+		n++
+		if opts.Progress != nil && n%ProgressRows == 0 {
+			opts.Progress(n)
+		}
+	}
+
+	start := time.Now()
+	result, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return res, ContextError(ctx, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return res, ContextError(ctx, err)
+	}
+	res.Rows, _ = result.RowsAffected()
+	switch {
+	case mode == ModeUpsert:
+		if err := merge(ctx, txn, table, mergeQuery, keepIdentity, &res); err != nil {
+			return res, fmt.Errorf("could not merge: %w", err)
+		}
+	case staging:
+		if res.Inserted, err = insertStaging(ctx, txn, table, loadHeader.Colnames); err != nil {
+			return res, fmt.Errorf("could not insert from staging table: %w", err)
+		}
+	default:
+		res.Inserted = res.Rows
+	}
+	if keepIdentity {
+		if err := reseed(ctx, txn, table); err != nil {
+			return res, fmt.Errorf("could not reseed identity: %w", err)
+		}
+	}
+	res.ServerTime += time.Since(start)
+	return res, nil
+}
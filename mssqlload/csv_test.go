@@ -0,0 +1,100 @@
+package mssqlload
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColTypes(t *testing.T) {
+	ct, err := ParseColTypes([]byte(` ["int!", "string"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := ct.Find(0, "id"); !ok || v != "int!" {
+		t.Errorf("expected int! at idx 0, got %q", v)
+	}
+	if _, ok := ct.Find(2, "x"); ok {
+		t.Errorf("expected no type at idx 2")
+	}
+
+	ct, err = ParseColTypes([]byte(`{"hp": "float"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := ct.Find(0, "hp"); !ok || v != "float" {
+		t.Errorf("expected float for hp, got %q", v)
+	}
+
+	if _, err := ParseColTypes([]byte(`"int"`)); err == nil {
+		t.Error("expected error for scalar types file")
+	}
+}
+
+func TestParseHeaderAndRow(t *testing.T) {
+	ct, _ := ParseColTypes([]byte(`{"hp": "float!"}`))
+	header := ParseHeader([]string{"id::int", "name", "hp", "legendary::bool!"}, ct, nil)
+
+	if !reflect.DeepEqual(header.Colnames, []string{"id", "name", "hp", "legendary"}) {
+		t.Errorf("unexpected column names %v", header.Colnames)
+	}
+	if !reflect.DeepEqual(header.Types, []string{"int", "string", "float", "bool"}) {
+		t.Errorf("unexpected types %v", header.Types)
+	}
+	if !reflect.DeepEqual(header.Colopt, []bool{false, false, true, true}) {
+		t.Errorf("unexpected nullability %v", header.Colopt)
+	}
+
+	row, err := ParseRow(header, []string{"1", "Wartortle", "NA", "yes"}, "NA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []any{int64(1), "Wartortle", nil, true}
+	if !reflect.DeepEqual(row, expected) {
+		t.Errorf("expected %v, got %v", expected, row)
+	}
+
+	if _, err := ParseRow(header, []string{"x", "a", "1", "0"}, "NA"); err == nil {
+		t.Error("expected error for invalid int")
+	}
+	if _, err := ParseRow(header, []string{"1"}, "NA"); err == nil {
+		t.Error("expected error for wrong number of columns")
+	}
+}
+
+func TestLoaderType(t *testing.T) {
+	cases := map[string]string{
+		"bigint":        "int",
+		"decimal(10,2)": "float",
+		"bit":           "bool",
+		"varchar(255)":  "string",
+		"datetime2(7)":  "string",
+	}
+	for sqlType, expected := range cases {
+		if got := LoaderType(sqlType); got != expected {
+			t.Errorf("expected %s for %s, got %s", expected, sqlType, got)
+		}
+	}
+}
+
+func TestLoadableColumns(t *testing.T) {
+	header := ParseHeader([]string{"ID::int", "name", "total::int", "version"}, ColTypes{}, nil)
+	cols := map[string]tableColumn{
+		"id":      {Identity: true},
+		"name":    {},
+		"total":   {ReadOnly: true},
+		"version": {ReadOnly: true},
+	}
+	keep, identity := loadableColumns(header, cols)
+	if len(keep) != 2 || keep[0] != 0 || keep[1] != 1 || identity != "ID" {
+		t.Fatalf("got %v, %q", keep, identity)
+	}
+	h := selectColumns(header, keep)
+	if h.Ncols != 2 || h.Colnames[1] != "name" || h.Types[0] != "int" {
+		t.Fatalf("got %+v", h)
+	}
+
+	// unknown table: everything is loaded
+	if keep, identity := loadableColumns(header, nil); len(keep) != 4 || identity != "" {
+		t.Fatalf("got %v, %q", keep, identity)
+	}
+}
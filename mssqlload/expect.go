@@ -0,0 +1,248 @@
+package mssqlload
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Row is a result row as decoded from newline delimited JSON. Numbers are
+// kept as json.Number.
+type Row map[string]any
+
+// CompareOptions controls how query results are compared with the expected
+// rows.
+type CompareOptions struct {
+	// Unordered ignores the order of the rows.
+	Unordered bool
+	// IgnoreColumns are removed from both sides before comparing.
+	IgnoreColumns []string
+	// Tolerance is the maximum absolute difference of two numbers that are
+	// still considered equal.
+	Tolerance float64
+}
+
+// RowDiff is a difference between the expected and the actual rows of a
+// batch. Expected or Actual is nil for a missing or an unexpected row.
+type RowDiff struct {
+	Batch int
+	// Row is the index of the row (in the expected rows, if present), only
+	// meaningful for ordered comparisons.
+	Row      int
+	Expected Row
+	Actual   Row
+}
+
+// ParseNDJSON reads the expected rows in the format written by Query with
+// FormatJSON: one JSON object per line, batches separated by "---".
+func ParseNDJSON(r io.Reader) ([][]Row, error) {
+	batches := [][]Row{nil}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch text {
+		case "":
+			continue
+		case "---":
+			batches = append(batches, nil)
+			continue
+		}
+		row, err := decodeRow([]byte(text))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		batches[len(batches)-1] = append(batches[len(batches)-1], row)
+	}
+	return batches, scanner.Err()
+}
+
+func decodeRow(b []byte) (Row, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var row Row
+	if err := dec.Decode(&row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// ResultRows groups the rows of the result sets by batch, converted to the
+// representation of ParseNDJSON. nbatches is the number of batches of the
+// script, batches without result set are empty.
+func ResultRows(results []ResultSet, nbatches int) ([][]Row, error) {
+	batches := make([][]Row, nbatches)
+	for _, rs := range results {
+		for rs.Batch >= len(batches) {
+			batches = append(batches, nil)
+		}
+		for _, vals := range rs.Rows {
+			b, err := json.Marshal(rowMap(rs.Columns, vals))
+			if err != nil {
+				return nil, err
+			}
+			row, err := decodeRow(b)
+			if err != nil {
+				return nil, err
+			}
+			batches[rs.Batch] = append(batches[rs.Batch], row)
+		}
+	}
+	return batches, nil
+}
+
+// WriteNDJSON writes rows in the format read by ParseNDJSON.
+func WriteNDJSON(w io.Writer, batches [][]Row) error {
+	for i, rows := range batches {
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		for _, row := range rows {
+			b, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(w, string(b)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Compare compares the expected with the actual rows of a batch.
+func Compare(batch int, expected, actual []Row, opts CompareOptions) []RowDiff {
+	expected = dropColumns(expected, opts.IgnoreColumns)
+	actual = dropColumns(actual, opts.IgnoreColumns)
+	if opts.Unordered {
+		return compareUnordered(batch, expected, actual, opts.Tolerance)
+	}
+
+	var diffs []RowDiff
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		d := RowDiff{Batch: batch, Row: i}
+		if i < len(expected) {
+			d.Expected = expected[i]
+		}
+		if i < len(actual) {
+			d.Actual = actual[i]
+		}
+		if d.Expected == nil || d.Actual == nil || !rowsEqual(d.Expected, d.Actual, opts.Tolerance) {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+func compareUnordered(batch int, expected, actual []Row, tol float64) []RowDiff {
+	matched := make([]bool, len(actual))
+	var diffs []RowDiff
+	for i, e := range expected {
+		found := false
+		for j, a := range actual {
+			if !matched[j] && rowsEqual(e, a, tol) {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, RowDiff{Batch: batch, Row: i, Expected: e})
+		}
+	}
+	for j, a := range actual {
+		if !matched[j] {
+			diffs = append(diffs, RowDiff{Batch: batch, Row: j, Actual: a})
+		}
+	}
+	return diffs
+}
+
+func dropColumns(rows []Row, cols []string) []Row {
+	if len(cols) == 0 {
+		return rows
+	}
+	res := make([]Row, len(rows))
+	for i, row := range rows {
+		r := make(Row, len(row))
+		for k, v := range row {
+			r[k] = v
+		}
+		for _, c := range cols {
+			delete(r, c)
+		}
+		res[i] = r
+	}
+	return res
+}
+
+func rowsEqual(a, b Row, tol float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, va := range a {
+		vb, ok := b[k]
+		if !ok || !valuesEqual(va, vb, tol) {
+			return false
+		}
+	}
+	return true
+}
+
+func valuesEqual(a, b any, tol float64) bool {
+	na, aIsNum := a.(json.Number)
+	nb, bIsNum := b.(json.Number)
+	if aIsNum && bIsNum {
+		if na == nb {
+			return true
+		}
+		fa, errA := strconv.ParseFloat(string(na), 64)
+		fb, errB := strconv.ParseFloat(string(nb), 64)
+		return errA == nil && errB == nil && math.Abs(fa-fb) <= tol
+	}
+	// strings, bools, nil and nested values
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+// FormatDiffs renders the differences in a diff-like format, "-" marks
+// expected, "+" actual rows.
+func FormatDiffs(w io.Writer, diffs []RowDiff, unordered bool) error {
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Batch < diffs[j].Batch })
+	batch := -1
+	for _, d := range diffs {
+		if d.Batch != batch {
+			batch = d.Batch
+			if _, err := fmt.Fprintf(w, "batch %d:\n", batch+1); err != nil {
+				return err
+			}
+		}
+		if !unordered {
+			fmt.Fprintf(w, "  row %d:\n", d.Row+1)
+		}
+		for _, x := range []struct {
+			prefix string
+			row    Row
+		}{{"-", d.Expected}, {"+", d.Actual}} {
+			if x.row == nil {
+				continue
+			}
+			b, err := json.Marshal(x.row)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "  %s %s\n", x.prefix, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package mssqlload
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseNDJSON(t *testing.T) {
+	batches, err := ParseNDJSON(strings.NewReader(`{"name":"Wartortle","hp":4}
+---
+{"hp":4}
+{"hp":5}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 1 || len(batches[1]) != 2 {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, batches); err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\"hp\":4,\"name\":\"Wartortle\"}\n---\n{\"hp\":4}\n{\"hp\":5}\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	if _, err := ParseNDJSON(strings.NewReader("{\"hp\":4}\n{hp}\n")); err == nil || !strings.HasPrefix(err.Error(), "line 2") {
+		t.Errorf("expected error in line 2, got %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	parse := func(s string) []Row {
+		b, err := ParseNDJSON(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b[0]
+	}
+	expected := parse("{\"name\":\"a\",\"hp\":1.0,\"ts\":1}\n{\"name\":\"b\",\"hp\":2,\"ts\":2}\n")
+	actual := parse("{\"name\":\"b\",\"hp\":2.001,\"ts\":3}\n{\"name\":\"a\",\"hp\":1,\"ts\":4}\n")
+
+	if diffs := Compare(0, expected, actual, CompareOptions{}); len(diffs) != 2 {
+		t.Errorf("expected 2 diffs, got %v", diffs)
+	}
+	opts := CompareOptions{Unordered: true, IgnoreColumns: []string{"ts"}, Tolerance: 0.01}
+	if diffs := Compare(0, expected, actual, opts); len(diffs) != 0 {
+		t.Errorf("expected no diffs, got %v", diffs)
+	}
+	opts.Tolerance = 0
+	diffs := Compare(0, expected, actual, opts)
+	if len(diffs) != 2 || diffs[0].Expected == nil || diffs[1].Actual == nil {
+		t.Fatalf("expected one missing and one unexpected row, got %v", diffs)
+	}
+
+	var buf bytes.Buffer
+	if err := FormatDiffs(&buf, diffs, true); err != nil {
+		t.Fatal(err)
+	}
+	out := "batch 1:\n  - {\"hp\":2,\"name\":\"b\"}\n  + {\"hp\":2.001,\"name\":\"b\"}\n"
+	if buf.String() != out {
+		t.Errorf("expected %q, got %q", out, buf.String())
+	}
+}
@@ -0,0 +1,100 @@
+package mssqlload
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// tableColumn describes a column of the target table of LoadCSV.
+type tableColumn struct {
+	Name string
+	// Type is the name of the type, without length, precision or scale.
+	Type string
+	// MaxLength is the length of string types in characters, -1 for max.
+	MaxLength  int
+	Nullable   bool
+	Identity   bool
+	HasDefault bool
+	// ReadOnly columns (computed, rowversion) cannot be loaded.
+	ReadOnly bool
+}
+
+const tableColumnsQuery = `SELECT c.name, ty.name,
+  IIF(ty.name IN ('nchar', 'nvarchar') AND c.max_length > 0, c.max_length / 2, c.max_length),
+  c.is_nullable, c.is_identity, CAST(IIF(c.default_object_id <> 0, 1, 0) AS bit),
+  CAST(IIF(c.is_computed = 1 OR ty.name = 'timestamp', 1, 0) AS bit)
+FROM sys.columns c
+JOIN sys.types ty ON ty.user_type_id = c.user_type_id
+WHERE c.object_id = OBJECT_ID(@p1)
+ORDER BY c.column_id`
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// tableColumns reads the columns of table, by lower case name. The result
+// is empty if the table does not exist (yet), e.g. for temp tables.
+func tableColumns(ctx context.Context, q queryer, table string) (map[string]tableColumn, error) {
+	rows, err := q.QueryContext(ctx, tableColumnsQuery, QuoteTableName(table))
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+	res := map[string]tableColumn{}
+	for rows.Next() {
+		var c tableColumn
+		if err := rows.Scan(&c.Name, &c.Type, &c.MaxLength, &c.Nullable, &c.Identity, &c.HasDefault, &c.ReadOnly); err != nil {
+			return nil, err
+		}
+		res[strings.ToLower(c.Name)] = c
+	}
+	return res, ContextError(ctx, rows.Err())
+}
+
+// loadableColumns returns the indexes of the header columns that can be
+// loaded and the identity column of the header, if any.
+func loadableColumns(header Header, cols map[string]tableColumn) ([]int, string) {
+	var keep []int
+	identity := ""
+	for i, name := range header.Colnames {
+		c := cols[strings.ToLower(name)]
+		if c.ReadOnly {
+			continue
+		}
+		if c.Identity {
+			identity = name
+		}
+		keep = append(keep, i)
+	}
+	return keep, identity
+}
+
+// selectColumns returns the header restricted to the columns keep.
+func selectColumns(header Header, keep []int) Header {
+	res := Header{Ncols: len(keep)}
+	for _, i := range keep {
+		res.Colnames = append(res.Colnames, header.Colnames[i])
+		res.Types = append(res.Types, header.Types[i])
+		res.Parsers = append(res.Parsers, header.Parsers[i])
+		res.Colopt = append(res.Colopt, header.Colopt[i])
+	}
+	return res
+}
+
+// reseed sets the identity of table to the maximum of the identity column,
+// if it is lower, so that later inserts do not collide with loaded rows.
+func reseed(ctx context.Context, txn *sql.Tx, table string) error {
+	literal := "N'" + strings.ReplaceAll(QuoteTableName(table), "'", "''") + "'"
+	_, err := txn.ExecContext(ctx, "DBCC CHECKIDENT ("+literal+", RESEED) WITH NO_INFOMSGS")
+	return ContextError(ctx, err)
+}
+
+func identityInsert(ctx context.Context, txn *sql.Tx, table string, on bool) error {
+	v := "OFF"
+	if on {
+		v = "ON"
+	}
+	_, err := txn.ExecContext(ctx, "SET IDENTITY_INSERT "+QuoteTableName(table)+" "+v)
+	return ContextError(ctx, err)
+}
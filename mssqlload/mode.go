@@ -0,0 +1,198 @@
+package mssqlload
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Load modes of LoadCSV.
+const (
+	// ModeAppend inserts the rows.
+	ModeAppend = "append"
+	// ModeTruncate truncates the table before inserting the rows. TRUNCATE
+	// fails if the table is referenced by a foreign key.
+	ModeTruncate = "truncate"
+	// ModeReplace deletes all rows before inserting the rows.
+	ModeReplace = "replace"
+	// ModeUpsert loads the rows into a staging table and merges them into
+	// the table by key: new rows are inserted, changed rows updated.
+	ModeUpsert = "upsert"
+)
+
+// stagingTable is the temp table of ModeUpsert and KeepIdentity, it lives
+// as long as the connection of the load transaction.
+const stagingTable = "#mssqlload_staging"
+
+func ParseMode(v string) (string, error) {
+	switch v {
+	case "", ModeAppend:
+		return ModeAppend, nil
+	case ModeTruncate, ModeReplace, ModeUpsert:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown mode %q, expected append, truncate, replace or upsert", v)
+}
+
+// stagingStatement creates an empty temp table with the columns of table.
+// The UNION prevents that the IDENTITY property is copied, so that the
+// staging table accepts the values of identity columns.
+func stagingStatement(table string, cols []string) string {
+	qcols := quoteNames(cols, "")
+	qtable := QuoteTableName(table)
+	return fmt.Sprintf("SELECT TOP 0 %s INTO %s FROM %s UNION ALL SELECT TOP 0 %s FROM %s",
+		qcols, stagingTable, qtable, qcols, qtable)
+}
+
+// mergeStatement merges the staging table into table by the key columns.
+// Rows are only updated if one of the other columns differs (NULL-safe,
+// using EXCEPT). The identity column is never updated and only inserted with
+// keepIdentity. The statement outputs the action per row.
+func mergeStatement(table string, cols []string, key []string, identity string, keepIdentity bool, deleteMissing bool) (string, error) {
+	isKey := map[string]bool{}
+	for _, k := range key {
+		isKey[k] = true
+	}
+	var on, values []string
+	for _, k := range key {
+		if !contains(cols, k) {
+			return "", fmt.Errorf("key column %s is not in the CSV header", k)
+		}
+		on = append(on, fmt.Sprintf("t.%s = s.%[1]s", QuoteName(k)))
+	}
+	var set, other, insert []string
+	for _, c := range cols {
+		if c == identity && !keepIdentity {
+			continue
+		}
+		insert = append(insert, c)
+		values = append(values, "s."+QuoteName(c))
+		if isKey[c] || c == identity {
+			continue
+		}
+		other = append(other, c)
+		set = append(set, fmt.Sprintf("t.%s = s.%[1]s", QuoteName(c)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "MERGE %s AS t\nUSING %s AS s\nON %s\n", QuoteTableName(table), stagingTable, strings.Join(on, " AND "))
+	if len(other) > 0 {
+		fmt.Fprintf(&b, "WHEN MATCHED AND EXISTS (SELECT %s EXCEPT SELECT %s) THEN\n  UPDATE SET %s\n",
+			quoteNames(other, "s."), quoteNames(other, "t."), strings.Join(set, ", "))
+	}
+	fmt.Fprintf(&b, "WHEN NOT MATCHED BY TARGET THEN\n  INSERT (%s) VALUES (%s)\n", quoteNames(insert, ""), strings.Join(values, ", "))
+	if deleteMissing {
+		b.WriteString("WHEN NOT MATCHED BY SOURCE THEN\n  DELETE\n")
+	}
+	b.WriteString("OUTPUT $action;")
+	return b.String(), nil
+}
+
+func quoteNames(names []string, prefix string) string {
+	q := make([]string, len(names))
+	for i, n := range names {
+		q[i] = prefix + QuoteName(n)
+	}
+	return strings.Join(q, ", ")
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// prepareTable empties the table for ModeTruncate and ModeReplace and
+// creates the staging table if needed. It returns the number of deleted
+// rows.
+func prepareTable(ctx context.Context, txn *sql.Tx, table string, cols []string, mode string, staging bool) (int64, error) {
+	if staging {
+		if _, err := txn.ExecContext(ctx, stagingStatement(table, cols)); err != nil {
+			return 0, fmt.Errorf("could not create staging table: %w", ContextError(ctx, err))
+		}
+	}
+	qtable := QuoteTableName(table)
+	switch mode {
+	case ModeTruncate:
+		// TRUNCATE does not report the number of rows
+		var n int64
+		if err := txn.QueryRowContext(ctx, "SELECT COUNT_BIG(*) FROM "+qtable).Scan(&n); err != nil {
+			return 0, ContextError(ctx, err)
+		}
+		if _, err := txn.ExecContext(ctx, "TRUNCATE TABLE "+qtable); err != nil {
+			return 0, ContextError(ctx, err)
+		}
+		return n, nil
+	case ModeReplace:
+		res, err := txn.ExecContext(ctx, "DELETE FROM "+qtable)
+		if err != nil {
+			return 0, ContextError(ctx, err)
+		}
+		return res.RowsAffected()
+	}
+	return 0, nil
+}
+
+// merge runs the MERGE statement and counts the actions.
+func merge(ctx context.Context, txn *sql.Tx, table string, query string, keepIdentity bool, res *CSVResult) error {
+	if keepIdentity {
+		if err := identityInsert(ctx, txn, table, true); err != nil {
+			return err
+		}
+	}
+	rows, err := txn.QueryContext(ctx, query)
+	if err != nil {
+		return ContextError(ctx, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			return err
+		}
+		switch action {
+		case "INSERT":
+			res.Inserted++
+		case "UPDATE":
+			res.Updated++
+		case "DELETE":
+			res.Deleted++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ContextError(ctx, err)
+	}
+	if keepIdentity {
+		if err := identityInsert(ctx, txn, table, false); err != nil {
+			return err
+		}
+	}
+	return dropStaging(ctx, txn)
+}
+
+// insertStaging inserts the rows of the staging table into table, with
+// IDENTITY_INSERT, as bulk copy cannot keep identity values.
+func insertStaging(ctx context.Context, txn *sql.Tx, table string, cols []string) (int64, error) {
+	if err := identityInsert(ctx, txn, table, true); err != nil {
+		return 0, err
+	}
+	qcols := quoteNames(cols, "")
+	res, err := txn.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", QuoteTableName(table), qcols, qcols, stagingTable))
+	if err != nil {
+		return 0, ContextError(ctx, err)
+	}
+	n, _ := res.RowsAffected()
+	if err := identityInsert(ctx, txn, table, false); err != nil {
+		return 0, err
+	}
+	return n, dropStaging(ctx, txn)
+}
+
+func dropStaging(ctx context.Context, txn *sql.Tx) error {
+	_, err := txn.ExecContext(ctx, "DROP TABLE "+stagingTable)
+	return ContextError(ctx, err)
+}
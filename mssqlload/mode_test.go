@@ -0,0 +1,79 @@
+package mssqlload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	if m, err := ParseMode(""); err != nil || m != ModeAppend {
+		t.Fatalf("got %q, %v", m, err)
+	}
+	if m, err := ParseMode("upsert"); err != nil || m != ModeUpsert {
+		t.Fatalf("got %q, %v", m, err)
+	}
+	if _, err := ParseMode("merge"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestStagingStatement(t *testing.T) {
+	got := stagingStatement("pokemon.pokemon", []string{"id", "name"})
+	want := "SELECT TOP 0 [id], [name] INTO #mssqlload_staging FROM [pokemon].[pokemon] UNION ALL SELECT TOP 0 [id], [name] FROM [pokemon].[pokemon]"
+	if got != want {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestMergeStatement(t *testing.T) {
+	got, err := mergeStatement("pokemon.pokemon", []string{"name", "hp", "type"}, []string{"name"}, "", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `MERGE [pokemon].[pokemon] AS t
+USING #mssqlload_staging AS s
+ON t.[name] = s.[name]
+WHEN MATCHED AND EXISTS (SELECT s.[hp], s.[type] EXCEPT SELECT t.[hp], t.[type]) THEN
+  UPDATE SET t.[hp] = s.[hp], t.[type] = s.[type]
+WHEN NOT MATCHED BY TARGET THEN
+  INSERT ([name], [hp], [type]) VALUES (s.[name], s.[hp], s.[type])
+WHEN NOT MATCHED BY SOURCE THEN
+  DELETE
+OUTPUT $action;`
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// only key columns: nothing to update
+	got, err = mergeStatement("t", []string{"a", "b"}, []string{"a", "b"}, "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "WHEN MATCHED") || strings.Contains(got, "DELETE") {
+		t.Fatalf("got %s", got)
+	}
+	if !strings.Contains(got, "ON t.[a] = s.[a] AND t.[b] = s.[b]") {
+		t.Fatalf("got %s", got)
+	}
+
+	if _, err := mergeStatement("t", []string{"a"}, []string{"id"}, "", false, false); err == nil {
+		t.Fatal("expected an error for a key column that is not in the header")
+	}
+}
+
+func TestMergeStatementIdentity(t *testing.T) {
+	got, err := mergeStatement("t", []string{"id", "name", "hp"}, []string{"name"}, "id", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "UPDATE SET t.[hp] = s.[hp]\n") || !strings.Contains(got, "INSERT ([name], [hp]) VALUES (s.[name], s.[hp])") {
+		t.Fatalf("got %s", got)
+	}
+	got, err = mergeStatement("t", []string{"id", "name", "hp"}, []string{"id"}, "id", true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "UPDATE SET t.[name] = s.[name], t.[hp] = s.[hp]\n") || !strings.Contains(got, "INSERT ([id], [name], [hp])") {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -0,0 +1,42 @@
+package mssqlload
+
+import "github.com/jwbargsten/go-mssql-load/util"
+
+// QuoteName quotes an identifier, like QUOTENAME in T-SQL.
+func QuoteName(name string) string {
+	return util.QuoteName(name)
+}
+
+// SplitName splits a multi-part name like "db.schema.table", see
+// util.SplitName.
+func SplitName(name string) []string {
+	return util.SplitName(name)
+}
+
+// SplitTableName splits "schema.table" into its parts, defaulting to dbo.
+// Brackets around the parts are removed. Of names with more parts, like
+// "db.schema.table", the last two parts are returned.
+func SplitTableName(name string) (string, string) {
+	parts := SplitName(name)
+	if len(parts) == 1 {
+		return "dbo", parts[0]
+	}
+	schema, table := parts[len(parts)-2], parts[len(parts)-1]
+	if schema == "" {
+		// db..table
+		schema = "dbo"
+	}
+	return schema, table
+}
+
+// QuoteTableName quotes "schema.table" (or just "table") as
+// [schema].[table]. A database, as in "db.schema.table", is kept.
+func QuoteTableName(name string) string {
+	parts := SplitName(name)
+	schema, table := SplitTableName(name)
+	quoted := QuoteName(schema) + "." + QuoteName(table)
+	if len(parts) > 2 {
+		quoted = QuoteName(parts[len(parts)-3]) + "." + quoted
+	}
+	return quoted
+}
@@ -0,0 +1,31 @@
+package mssqlload
+
+import (
+	"testing"
+)
+
+func TestSplitTableName(t *testing.T) {
+	schema, table := SplitTableName("[pokemon].[pokemon]")
+	if schema != "pokemon" || table != "pokemon" {
+		t.Fatalf("got %s.%s", schema, table)
+	}
+	schema, table = SplitTableName("pokemon")
+	if schema != "dbo" || table != "pokemon" {
+		t.Fatalf("got %s.%s", schema, table)
+	}
+	if v := QuoteTableName("pokemon"); v != "[dbo].[pokemon]" {
+		t.Fatalf("got %s", v)
+	}
+}
+
+func TestSplitTableNameQuoted(t *testing.T) {
+	if schema, table := SplitTableName("[a.b].[c]"); schema != "a.b" || table != "c" {
+		t.Errorf("got %s.%s", schema, table)
+	}
+	if schema, table := SplitTableName("pokedb..pokemon"); schema != "dbo" || table != "pokemon" {
+		t.Errorf("got %s.%s", schema, table)
+	}
+	if v := QuoteTableName("pokedb.pokemon.pokemon"); v != "[pokedb].[pokemon].[pokemon]" {
+		t.Errorf("got %s", v)
+	}
+}
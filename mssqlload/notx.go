@@ -0,0 +1,95 @@
+package mssqlload
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrNotInTransaction is returned by LoadSQL if a batch contains a
+// statement that SQL Server does not allow in a transaction, unless
+// LoadSQLOptions.NoTransaction is set.
+var ErrNotInTransaction = errors.New("statement is not allowed in a transaction")
+
+var notInTransaction = regexp.MustCompile(`(?i)\b(?:(CREATE|ALTER|DROP)\s+DATABASE\s+(\S+)|(BACKUP|RESTORE)\s+(?:DATABASE|LOG)\b|(RECONFIGURE)\b|(CREATE|ALTER|DROP)\s+FULLTEXT\s+(?:CATALOG|INDEX)\b)`)
+
+// nonTransactional returns the first statement of stmt that is not allowed
+// in a transaction, e.g. "ALTER DATABASE", or "" if there is none. Comments
+// and string literals are ignored.
+func nonTransactional(stmt string) string {
+	for _, m := range notInTransaction.FindAllStringSubmatch(stripLiterals(stmt), -1) {
+		switch {
+		case m[1] != "":
+			// ALTER DATABASE SCOPED CONFIGURATION, CREATE DATABASE AUDIT
+			// SPECIFICATION, ... are allowed
+			switch strings.ToUpper(m[2]) {
+			case "SCOPED", "AUDIT", "ENCRYPTION":
+				continue
+			}
+			return strings.ToUpper(m[1]) + " DATABASE"
+		case m[3] != "":
+			return strings.ToUpper(m[3])
+		case m[4] != "":
+			return strings.ToUpper(m[4])
+		default:
+			return strings.ToUpper(m[5]) + " FULLTEXT"
+		}
+	}
+	return ""
+}
+
+// stripLiterals replaces comments, string literals and quoted identifiers
+// of stmt by spaces. Line breaks are kept, so that lines can be counted.
+func stripLiterals(stmt string) string {
+	var b strings.Builder
+	var quote byte
+	comment := 0
+	for i := 0; i < len(stmt); i++ {
+		c := stmt[i]
+		var next byte
+		if i+1 < len(stmt) {
+			next = stmt[i+1]
+		}
+		switch {
+		case comment > 0:
+			if c == '*' && next == '/' {
+				comment--
+				i++
+			} else if c == '/' && next == '*' {
+				comment++
+				i++
+			}
+			if c != '\n' {
+				c = ' '
+			}
+		case quote != 0:
+			if c == quote {
+				if next == quote {
+					i++
+				} else {
+					quote = 0
+				}
+			}
+			if c != '\n' {
+				c = ' '
+			}
+		case c == '-' && next == '-':
+			for i < len(stmt) && stmt[i] != '\n' {
+				i++
+			}
+			c = '\n'
+		case c == '/' && next == '*':
+			comment++
+			i++
+			c = ' '
+		case c == '\'' || c == '"':
+			quote = c
+			c = ' '
+		case c == '[':
+			quote = ']'
+			c = ' '
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
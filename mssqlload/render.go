@@ -0,0 +1,210 @@
+package mssqlload
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatTable Format = "table"
+	FormatCSV   Format = "csv"
+)
+
+func ParseFormat(v string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(v))); f {
+	case FormatJSON, FormatTable, FormatCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (supported: json, table, csv)", v)
+}
+
+// ResultSet is a single result set of a query.
+type ResultSet struct {
+	// Batch is the index of the batch (separated by "GO") the result set
+	// belongs to.
+	Batch   int
+	Columns []string
+	Rows    [][]any
+}
+
+// rowWriter renders rows in one of the output formats.
+type rowWriter interface {
+	header(cols []string) error
+	row(vals []any) error
+	flush() error
+}
+
+func newRowWriter(w io.Writer, format Format) rowWriter {
+	switch format {
+	case FormatTable:
+		return &tableWriter{tw: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+	case FormatCSV:
+		return &csvWriter{cw: csv.NewWriter(w)}
+	default:
+		return &jsonWriter{w: w}
+	}
+}
+
+// WriteRows renders the current result set of rows to w and returns the
+// number of rows written. It does not advance to the next result set.
+func WriteRows(w io.Writer, rows *sql.Rows, format Format) (int64, error) {
+	rs, err := scanResultSet(rows, newRowWriter(w, format), false)
+	return int64(len(rs.Rows)), err
+}
+
+// WriteResultSet renders the collected result set rs to w, like WriteRows.
+func WriteResultSet(w io.Writer, rs ResultSet, format Format) error {
+	rw := newRowWriter(w, format)
+	if err := rw.header(rs.Columns); err != nil {
+		return err
+	}
+	for _, row := range rs.Rows {
+		if err := rw.row(row); err != nil {
+			return err
+		}
+	}
+	return rw.flush()
+}
+
+// scanResultSet reads the current result set. If rw is not nil, the rows are
+// rendered. If collect is false, the returned result set contains nil rows
+// (but the correct number of them).
+func scanResultSet(rows *sql.Rows, rw rowWriter, collect bool) (ResultSet, error) {
+	var rs ResultSet
+	cols, err := rows.Columns()
+	if err != nil {
+		return rs, err
+	}
+	rs.Columns = cols
+	if len(cols) == 0 {
+		// e.g. an INSERT statement
+		rw = nil
+	}
+	if rw != nil {
+		if err := rw.header(cols); err != nil {
+			return rs, err
+		}
+	}
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return rs, err
+		}
+		if rw != nil {
+			if err := rw.row(vals); err != nil {
+				return rs, err
+			}
+		}
+		if !collect {
+			vals = nil
+		}
+		rs.Rows = append(rs.Rows, vals)
+	}
+	if rw != nil {
+		if err := rw.flush(); err != nil {
+			return rs, err
+		}
+	}
+	return rs, rows.Err()
+}
+
+type jsonWriter struct {
+	w    io.Writer
+	cols []string
+}
+
+func (j *jsonWriter) header(cols []string) error {
+	j.cols = cols
+	return nil
+}
+
+func rowMap(cols []string, vals []any) map[string]any {
+	res := make(map[string]any, len(vals))
+	for i, v := range vals {
+		res[cols[i]] = v
+	}
+	return res
+}
+
+func (j *jsonWriter) row(vals []any) error {
+	jsonContent, err := json.Marshal(rowMap(j.cols, vals))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(j.w, string(jsonContent))
+	return err
+}
+
+func (j *jsonWriter) flush() error { return nil }
+
+type tableWriter struct {
+	tw *tabwriter.Writer
+}
+
+func (t *tableWriter) header(cols []string) error {
+	fmt.Fprintln(t.tw, strings.Join(cols, "\t"))
+	sep := make([]string, len(cols))
+	for i, c := range cols {
+		sep[i] = strings.Repeat("-", len(c))
+	}
+	_, err := fmt.Fprintln(t.tw, strings.Join(sep, "\t"))
+	return err
+}
+
+func (t *tableWriter) row(vals []any) error {
+	fields := make([]string, len(vals))
+	for i, v := range vals {
+		fields[i] = FormatValue(v, "NULL")
+	}
+	_, err := fmt.Fprintln(t.tw, strings.Join(fields, "\t"))
+	return err
+}
+
+func (t *tableWriter) flush() error { return t.tw.Flush() }
+
+type csvWriter struct {
+	cw *csv.Writer
+}
+
+func (c *csvWriter) header(cols []string) error { return c.cw.Write(cols) }
+
+func (c *csvWriter) row(vals []any) error {
+	fields := make([]string, len(vals))
+	for i, v := range vals {
+		fields[i] = FormatValue(v, "")
+	}
+	return c.cw.Write(fields)
+}
+
+func (c *csvWriter) flush() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+// FormatValue converts a scanned column value into its textual
+// representation. NULL values are rendered as nullstr.
+func FormatValue(v any, nullstr string) string {
+	switch x := v.(type) {
+	case nil:
+		return nullstr
+	case []byte:
+		return string(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(x)
+	}
+}
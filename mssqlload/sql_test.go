@@ -0,0 +1,87 @@
+package mssqlload
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitBatches(t *testing.T) {
+	script := `CREATE SCHEMA pokemon;
+GO
+
+GO
+CREATE TABLE pokemon.pokemon (name varchar(255));
+
+INSERT INTO pokemon.pokemon VALUES ('Wartortle');
+go
+`
+	expected := []string{
+		"CREATE SCHEMA pokemon;\n",
+		"CREATE TABLE pokemon.pokemon (name varchar(255));\nINSERT INTO pokemon.pokemon VALUES ('Wartortle');\n",
+	}
+	if res := SplitBatches(script); !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %q, got %q", expected, res)
+	}
+}
+
+func TestSplitBatchLines(t *testing.T) {
+	script := `CREATE SCHEMA pokemon;
+GO
+
+GO
+-- the table
+CREATE TABLE pokemon.pokemon (name varchar(255));
+
+INSERT INTO pokemon.pokemon VALUES ('Wartortle');
+go
+PRINT 'x'
+GO 2
+`
+	res := SplitBatchLines(script)
+	var lines [][2]int
+	for _, b := range res {
+		lines = append(lines, [2]int{b.StartLine, b.EndLine})
+	}
+	expected := [][2]int{{1, 1}, {5, 8}, {10, 10}, {10, 10}}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+	if res[1].SQL != "-- the table\nCREATE TABLE pokemon.pokemon (name varchar(255));\nINSERT INTO pokemon.pokemon VALUES ('Wartortle');\n" {
+		t.Errorf("got %q", res[1].SQL)
+	}
+}
+
+func TestContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	driverErr := errors.New("mssql: operation cancelled")
+	if err := ContextError(ctx, driverErr); err != driverErr {
+		t.Errorf("expected error to be unchanged while ctx is active, got %v", err)
+	}
+	cancel()
+	if err := ContextError(ctx, driverErr); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error to wrap context.Canceled, got %v", err)
+	}
+	if err := ContextError(ctx, nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestNonTransactional(t *testing.T) {
+	cases := map[string]string{
+		"CREATE DATABASE pokedb": "CREATE DATABASE",
+		"IF DB_ID('x') IS NULL\n  alter database x set recovery simple": "ALTER DATABASE",
+		"BACKUP DATABASE pokedb TO DISK = 'a.bak'":                      "BACKUP",
+		"EXEC sp_configure 'x', 1; RECONFIGURE;":                        "RECONFIGURE",
+		"CREATE FULLTEXT CATALOG ft":                                    "CREATE FULLTEXT",
+		"ALTER DATABASE SCOPED CONFIGURATION SET MAXDOP = 1":            "",
+		"-- run CREATE DATABASE first\nSELECT 'DROP DATABASE x'":        "",
+		"SELECT [backup database] FROM t /* RESTORE LOG */":             "",
+	}
+	for stmt, expected := range cases {
+		if got := nonTransactional(stmt); got != expected {
+			t.Errorf("nonTransactional(%q) = %q, expected %q", stmt, got, expected)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package mssqlload
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxProblems is the number of problems ValidateCSV reports in detail.
+const MaxProblems = 100
+
+// Problem is a validation problem of the CSV data. Line is the line in the
+// CSV data (1 is the header), Column is empty for problems of a whole row.
+type Problem struct {
+	Line    int
+	Column  string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Column == "" {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return fmt.Sprintf("line %d, column %s: %s", p.Line, p.Column, p.Message)
+}
+
+type ValidationResult struct {
+	Header Header
+	Rows   int64
+	// Problems are the first MaxProblems problems, NProblems counts all.
+	Problems  []Problem
+	NProblems int
+}
+
+func (r *ValidationResult) add(p Problem) {
+	r.NProblems++
+	if len(r.Problems) < MaxProblems {
+		r.Problems = append(r.Problems, p)
+	}
+}
+
+// ValidateCSV parses and validates the CSV data read from r like LoadCSV
+// would load it into table, without sending any rows. The header is checked
+// against the columns of the table, every row against the types, the
+// nullability and the lengths of the columns.
+func ValidateCSV(ctx context.Context, db *sql.DB, table string, r io.Reader, opts CSVOptions) (ValidationResult, error) {
+	var res ValidationResult
+	mode, err := ParseMode(opts.Mode)
+	if err != nil {
+		return res, err
+	}
+	cols, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return res, fmt.Errorf("could not read columns of %s: %w", table, err)
+	}
+	if len(cols) == 0 {
+		return res, fmt.Errorf("table %s not found", table)
+	}
+
+	csvReader := csv.NewReader(r)
+	if opts.Separator != 0 {
+		csvReader.Comma = opts.Separator
+	}
+	rawHeader, err := csvReader.Read()
+	if err != nil {
+		return res, fmt.Errorf("could not read csv: %w", err)
+	}
+	header := ParseHeader(rawHeader, opts.ColTypes, opts.Log)
+	res.Header = header
+	for _, p := range validateHeader(header, cols, opts, mode) {
+		res.add(p)
+	}
+
+	err = validateRows(ctx, csvReader, header, cols, opts, &res)
+	return res, err
+}
+
+// validateRows validates the rows after the header.
+func validateRows(ctx context.Context, csvReader *csv.Reader, header Header, cols map[string]tableColumn, opts CSVOptions, res *ValidationResult) error {
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		res.Rows++
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if errors.Is(err, csv.ErrFieldCount) {
+				res.add(Problem{Line: parseErr.Line, Message: fmt.Sprintf("expected %d columns, got %d", header.Ncols, len(record))})
+				continue
+			}
+			// the reader cannot recover from other errors, e.g. quotes
+			res.add(Problem{Line: parseErr.Line, Message: parseErr.Err.Error()})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := csvReader.FieldPos(0)
+		for _, p := range validateRecord(line, header, record, cols, opts.NullString) {
+			res.add(p)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func validateHeader(header Header, cols map[string]tableColumn, opts CSVOptions, mode string) []Problem {
+	var problems []Problem
+	add := func(column string, format string, args ...any) {
+		problems = append(problems, Problem{Line: 1, Column: column, Message: fmt.Sprintf(format, args...)})
+	}
+	inHeader := map[string]bool{}
+	for i, name := range header.Colnames {
+		key := strings.ToLower(name)
+		if inHeader[key] {
+			add(name, "duplicate column")
+		}
+		inHeader[key] = true
+		c, ok := cols[key]
+		switch {
+		case !ok:
+			add(name, "column does not exist in the table")
+		case c.ReadOnly:
+			// skipped by LoadCSV
+		case !typeCompatible(header.Types[i], c.Type):
+			add(name, "type %s cannot be loaded into a %s column", header.Types[i], c.Type)
+		}
+	}
+	var names []string
+	for key := range cols {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		c := cols[key]
+		if !inHeader[key] && !c.Nullable && !c.HasDefault && !c.Identity && !c.ReadOnly {
+			add(c.Name, "NOT NULL column without default is missing")
+		}
+	}
+	for _, k := range opts.Key {
+		if !inHeader[strings.ToLower(k)] {
+			add(k, "key column is missing")
+		}
+	}
+	if mode == ModeUpsert && len(opts.Key) == 0 {
+		problems = append(problems, Problem{Line: 1, Message: "mode upsert needs key columns"})
+	}
+	return problems
+}
+
+func validateRecord(line int, header Header, record []string, cols map[string]tableColumn, nullstr string) []Problem {
+	var problems []Problem
+	for i, v := range record {
+		name := header.Colnames[i]
+		c, ok := cols[strings.ToLower(name)]
+		if !ok || c.ReadOnly {
+			continue
+		}
+		if header.Colopt[i] && v == nullstr {
+			if !c.Nullable {
+				problems = append(problems, Problem{Line: line, Column: name, Message: "NULL in a NOT NULL column"})
+			}
+			continue
+		}
+		if _, err := header.Parsers[i](v); err != nil {
+			problems = append(problems, Problem{Line: line, Column: name, Message: fmt.Sprintf("invalid %s %q", header.Types[i], v)})
+			continue
+		}
+		if header.Types[i] == "string" && c.MaxLength > 0 && isStringType(c.Type) && utf8.RuneCountInString(v) > c.MaxLength {
+			problems = append(problems, Problem{Line: line, Column: name,
+				Message: fmt.Sprintf("value with %d characters exceeds %s(%d)", utf8.RuneCountInString(v), c.Type, c.MaxLength)})
+		}
+	}
+	return problems
+}
+
+func isStringType(sqlType string) bool {
+	switch sqlType {
+	case "char", "varchar", "nchar", "nvarchar", "text", "ntext":
+		return true
+	}
+	return false
+}
+
+// typeCompatible reports whether bulk copy accepts the values of the loader
+// type for the SQL type.
+func typeCompatible(loaderType string, sqlType string) bool {
+	numeric := sqlType == "decimal" || sqlType == "numeric"
+	switch LoaderType(sqlType) {
+	case "int":
+		return loaderType == "int" || loaderType == "float"
+	case "float":
+		if sqlType == "money" || sqlType == "smallmoney" {
+			// not supported by the bulk copy of go-mssqldb
+			return false
+		}
+		return loaderType == "int" || loaderType == "float" || (numeric && loaderType == "string")
+	case "bool":
+		return loaderType == "bool"
+	}
+	switch {
+	case isStringType(sqlType):
+		return loaderType == "string" || loaderType == "int"
+	case sqlType == "uniqueidentifier", sqlType == "binary", sqlType == "varbinary", sqlType == "image":
+		// need []byte
+		return false
+	}
+	// date and time types
+	return loaderType == "string"
+}
@@ -0,0 +1,106 @@
+package mssqlload
+
+import (
+	"context"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testColumns() map[string]tableColumn {
+	return map[string]tableColumn{
+		"id":      {Name: "id", Type: "int", Identity: true},
+		"name":    {Name: "name", Type: "nvarchar", MaxLength: 5},
+		"hp":      {Name: "hp", Type: "decimal", Nullable: true},
+		"created": {Name: "created", Type: "datetime2", HasDefault: true},
+		"code":    {Name: "code", Type: "char", MaxLength: 3},
+		"total":   {Name: "total", Type: "int", ReadOnly: true},
+	}
+}
+
+func TestValidateHeader(t *testing.T) {
+	header := ParseHeader([]string{"id::int", "name", "hp::bool", "Name", "other", "total::int"}, ColTypes{}, nil)
+	problems := validateHeader(header, testColumns(), CSVOptions{Key: []string{"id", "nope"}}, ModeUpsert)
+	expected := []Problem{
+		{Line: 1, Column: "hp", Message: "type bool cannot be loaded into a decimal column"},
+		{Line: 1, Column: "Name", Message: "duplicate column"},
+		{Line: 1, Column: "other", Message: "column does not exist in the table"},
+		{Line: 1, Column: "code", Message: "NOT NULL column without default is missing"},
+		{Line: 1, Column: "nope", Message: "key column is missing"},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected %v, got %v", expected, problems)
+	}
+}
+
+func TestValidateRecord(t *testing.T) {
+	header := ParseHeader([]string{"id::int", "name::string!", "hp::float!", "code", "total::int"}, ColTypes{}, nil)
+	cols := testColumns()
+	if problems := validateRecord(2, header, []string{"1", "Bulba", "NA", "abc", "x"}, cols, "NA"); len(problems) > 0 {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+	problems := validateRecord(3, header, []string{"x", "NA", "1.5", "äbcd", "1"}, cols, "NA")
+	expected := []Problem{
+		{Line: 3, Column: "id", Message: `invalid int "x"`},
+		{Line: 3, Column: "name", Message: "NULL in a NOT NULL column"},
+		{Line: 3, Column: "code", Message: "value with 4 characters exceeds char(3)"},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected %v, got %v", expected, problems)
+	}
+}
+
+func TestValidateRows(t *testing.T) {
+	r := csv.NewReader(strings.NewReader("name,code\nBulba,abc\nIvy\n\"x\"y,2\nVenu,def\n"))
+	rawHeader, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := ParseHeader(rawHeader, ColTypes{}, nil)
+	var res ValidationResult
+	if err := validateRows(context.Background(), r, header, testColumns(), CSVOptions{}, &res); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Problem{
+		{Line: 3, Message: "expected 2 columns, got 1"},
+		{Line: 4, Message: `extraneous or missing " in quoted-field`},
+	}
+	if res.Rows != 3 || !reflect.DeepEqual(res.Problems, expected) {
+		t.Errorf("expected 3 rows and %v, got %d rows and %v", expected, res.Rows, res.Problems)
+	}
+}
+
+func TestTypeCompatible(t *testing.T) {
+	cases := []struct {
+		loaderType, sqlType string
+		ok                  bool
+	}{
+		{"int", "bigint", true},
+		{"string", "int", false},
+		{"float", "decimal", true},
+		{"string", "numeric", true},
+		{"string", "float", false},
+		{"float", "money", false},
+		{"bool", "bit", true},
+		{"int", "bit", false},
+		{"int", "varchar", true},
+		{"string", "uniqueidentifier", false},
+		{"string", "datetime2", true},
+		{"int", "date", false},
+	}
+	for _, c := range cases {
+		if got := typeCompatible(c.loaderType, c.sqlType); got != c.ok {
+			t.Errorf("expected %v for %s into %s, got %v", c.ok, c.loaderType, c.sqlType, got)
+		}
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	if s := (Problem{Line: 3, Column: "id", Message: "bad"}).String(); s != "line 3, column id: bad" {
+		t.Errorf("unexpected %q", s)
+	}
+	if s := (Problem{Line: 4, Message: "bad"}).String(); s != "line 4: bad" {
+		t.Errorf("unexpected %q", s)
+	}
+}
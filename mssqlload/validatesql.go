@@ -0,0 +1,255 @@
+package mssqlload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"go.uber.org/zap"
+)
+
+// Validation modes of ValidateSQL.
+const (
+	// ValidateParse checks the syntax (SET PARSEONLY ON).
+	ValidateParse = "parse"
+	// ValidateCompile also resolves objects and columns (SET NOEXEC ON).
+	ValidateCompile = "noexec"
+)
+
+// ErrValidationOption is reported by ValidateSQL for batches that set
+// NOEXEC or PARSEONLY themselves. Such a batch is not sent to the server:
+// after SET NOEXEC OFF, the rest of it would be executed.
+var ErrValidationOption = errors.New("batch sets NOEXEC or PARSEONLY and cannot be validated without executing it")
+
+var validationOption = regexp.MustCompile(`(?i)\bSET\s+(?:\w+\s*,\s*)*(?:NOEXEC|PARSEONLY)(?:\s*,\s*\w+)*\s+(?:ON|OFF)\b`)
+
+type ValidateSQLOptions struct {
+	// Mode is ValidateParse or ValidateCompile (default).
+	Mode string
+	// Describe describes the first result set of each batch with
+	// sp_describe_first_result_set, which also reports binding errors of
+	// queries.
+	Describe bool
+	// StatementTimeout limits the execution time of each batch, 0 means no
+	// limit.
+	StatementTimeout time.Duration
+	Log              *zap.SugaredLogger
+}
+
+// BatchError is an error of the server in a batch. Batch is 1-based, Line
+// is the line in the script, 0 if unknown.
+type BatchError struct {
+	Batch              int
+	StartLine, EndLine int
+	Line               int
+	Err                error
+}
+
+func (e *BatchError) Error() string {
+	pos := fmt.Sprintf("batch %d", e.Batch)
+	if e.Line > 0 {
+		pos += fmt.Sprintf(", line %d", e.Line)
+	}
+	return pos + ": " + e.Err.Error()
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// DescribedColumn is a column of the first result set of a batch.
+type DescribedColumn struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+type SQLValidationResult struct {
+	Batches []Batch
+	Errors  []*BatchError
+	// Columns are the columns of the first result set by batch index, only
+	// with ValidateSQLOptions.Describe.
+	Columns map[int][]DescribedColumn
+}
+
+// ValidateSQL sends all batches of the script read from r to the server
+// with SET PARSEONLY ON or SET NOEXEC ON, so that syntax and (for NOEXEC)
+// binding errors are reported without executing anything. All batches are
+// validated, the errors of the server are collected in the result. Objects
+// created by the script do not exist while it is validated, so batches that
+// use them fail with NOEXEC. Batches that set NOEXEC or PARSEONLY are not
+// sent, they are reported with ErrValidationOption.
+func ValidateSQL(ctx context.Context, db *sql.DB, r io.Reader, opts ValidateSQLOptions) (SQLValidationResult, error) {
+	log := logger(opts.Log)
+	var res SQLValidationResult
+	mode := opts.Mode
+	if mode == "" {
+		mode = ValidateCompile
+	}
+	option := map[string]string{ValidateParse: "PARSEONLY", ValidateCompile: "NOEXEC"}[mode]
+	if option == "" {
+		return res, fmt.Errorf("invalid validation mode %q, expected %s or %s", mode, ValidateParse, ValidateCompile)
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return res, err
+	}
+	script := string(raw)
+
+	// the options are set per session
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return res, ContextError(ctx, err)
+	}
+	defer conn.Close()
+
+	res.Batches = SplitBatchLines(script)
+	for i, b := range res.Batches {
+		log.Debugw("validating batch", "batch", i, "line", b.StartLine)
+		if n := validationOptionLine(b.SQL); n > 0 {
+			res.Errors = append(res.Errors, &BatchError{
+				Batch:     i + 1,
+				StartLine: b.StartLine,
+				EndLine:   b.EndLine,
+				Line:      scriptLine(script, b, n),
+				Err:       ErrValidationOption,
+			})
+			continue
+		}
+		err := validateBatch(ctx, conn, option, b.SQL, opts.StatementTimeout)
+		if err == nil && opts.Describe {
+			var cols []DescribedColumn
+			cols, err = describeBatch(ctx, conn, b.SQL, opts.StatementTimeout)
+			if len(cols) > 0 {
+				if res.Columns == nil {
+					res.Columns = map[int][]DescribedColumn{}
+				}
+				res.Columns[i] = cols
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil || !isServerError(err) {
+			return res, fmt.Errorf("batch %d: %w", i+1, err)
+		}
+		res.Errors = append(res.Errors, batchErrors(script, i, b, err)...)
+	}
+	return res, nil
+}
+
+func validateBatch(ctx context.Context, conn *sql.Conn, option string, stmt string, timeout time.Duration) error {
+	if _, err := conn.ExecContext(ctx, "SET "+option+" ON"); err != nil {
+		return ContextError(ctx, err)
+	}
+	stmtCtx, cancel := withStatementTimeout(ctx, timeout)
+	_, err := conn.ExecContext(stmtCtx, stmt)
+	err = ContextError(stmtCtx, err)
+	cancel()
+	// SET NOEXEC OFF and SET PARSEONLY OFF are processed even if the option
+	// is on
+	if _, offErr := conn.ExecContext(ctx, "SET "+option+" OFF"); offErr != nil && err == nil {
+		err = ContextError(ctx, offErr)
+	}
+	return err
+}
+
+// validationOptionLine returns the line of stmt with the first SET NOEXEC or
+// SET PARSEONLY, 0 if there is none. Comments and string literals are
+// ignored.
+func validationOptionLine(stmt string) int {
+	stripped := stripLiterals(stmt)
+	loc := validationOption.FindStringIndex(stripped)
+	if loc == nil {
+		return 0
+	}
+	return strings.Count(stripped[:loc[0]], "\n") + 1
+}
+
+func describeBatch(ctx context.Context, conn *sql.Conn, stmt string, timeout time.Duration) ([]DescribedColumn, error) {
+	ctx, cancel := withStatementTimeout(ctx, timeout)
+	defer cancel()
+	rows, err := conn.QueryContext(ctx, "EXEC sp_describe_first_result_set @tsql = @p1", stmt)
+	if err != nil {
+		return nil, ContextError(ctx, err)
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var res []DescribedColumn
+	for rows.Next() {
+		values := make([]any, len(names))
+		for i := range values {
+			values[i] = new(any)
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		var c DescribedColumn
+		for i, name := range names {
+			v := *values[i].(*any)
+			switch name {
+			case "name":
+				c.Name, _ = v.(string)
+			case "system_type_name":
+				c.Type, _ = v.(string)
+			case "is_nullable":
+				c.Nullable, _ = v.(bool)
+			}
+		}
+		res = append(res, c)
+	}
+	return res, ContextError(ctx, rows.Err())
+}
+
+func isServerError(err error) bool {
+	var sqlErr mssql.Error
+	return errors.As(err, &sqlErr)
+}
+
+// batchErrors splits the errors of the server for batch b and attributes
+// them to the lines of the script.
+func batchErrors(script string, i int, b Batch, err error) []*BatchError {
+	var sqlErr mssql.Error
+	errors.As(err, &sqlErr)
+	all := sqlErr.All
+	if len(all) == 0 {
+		all = []mssql.Error{sqlErr}
+	}
+	var res []*BatchError
+	for _, e := range all {
+		res = append(res, &BatchError{
+			Batch:     i + 1,
+			StartLine: b.StartLine,
+			EndLine:   b.EndLine,
+			Line:      scriptLine(script, b, int(e.LineNo)),
+			Err:       e,
+		})
+	}
+	return res
+}
+
+// scriptLine returns the line of the script of line n of batch b. Empty
+// lines are not sent to the server, see SplitBatches.
+func scriptLine(script string, b Batch, n int) int {
+	if b.StartLine == 0 || n <= 0 {
+		return 0
+	}
+	lines := strings.Split(script, "\n")
+	for l := b.StartLine; l <= b.EndLine && l <= len(lines); l++ {
+		if strings.TrimSpace(lines[l-1]) == "" {
+			continue
+		}
+		n--
+		if n == 0 {
+			return l
+		}
+	}
+	return 0
+}
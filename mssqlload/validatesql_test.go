@@ -0,0 +1,53 @@
+package mssqlload
+
+import (
+	"fmt"
+	"testing"
+
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+func TestScriptLine(t *testing.T) {
+	script := "SELECT 1\nGO\n\nSELECT a\n\n  FROM t\nWHERE x\nGO\n"
+	b := SplitBatchLines(script)[1]
+	cases := map[int]int{1: 4, 2: 6, 3: 7, 4: 0, 0: 0}
+	for n, expected := range cases {
+		if got := scriptLine(script, b, n); got != expected {
+			t.Errorf("expected line %d for batch line %d, got %d", expected, n, got)
+		}
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	script := "SELECT 1\nGO\nSELECT a\nFROM t\n"
+	first := mssql.Error{Number: 207, Message: "Invalid column name 'a'.", LineNo: 1}
+	last := mssql.Error{Number: 208, Message: "Invalid object name 't'.", LineNo: 2}
+	last.All = []mssql.Error{first, last}
+	b := SplitBatchLines(script)[1]
+	errs := batchErrors(script, 1, b, fmt.Errorf("wrapped: %w", last))
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(errs))
+	}
+	if s := errs[0].Error(); s != "batch 2, line 3: mssql: Invalid column name 'a'." {
+		t.Errorf("unexpected error %q", s)
+	}
+	if errs[1].Line != 4 || errs[1].StartLine != 3 || errs[1].EndLine != 4 {
+		t.Errorf("unexpected position %+v", errs[1])
+	}
+}
+
+func TestValidationOptionLine(t *testing.T) {
+	cases := map[string]int{
+		"SELECT 1":                                   0,
+		"SELECT 1\nSET NOEXEC OFF\nDROP TABLE t":     2,
+		"SET ANSI_NULLS, parseonly ON":               1,
+		"-- SET NOEXEC OFF\nSELECT 'SET NOEXEC OFF'": 0,
+		"/* a\nb */ SELECT 1\nset  noexec\toff":      3,
+		"UPDATE t SET noexec = 1":                    0,
+	}
+	for stmt, expected := range cases {
+		if got := validationOptionLine(stmt); got != expected {
+			t.Errorf("expected line %d for %q, got %d", expected, stmt, got)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package mssqltest provides helpers for tests against a MSSQL server: a
+// connection configured like the go-mssql-load CLI, throwaway databases,
+// fixture loading, transaction-per-test isolation and assertions.
+//
+// The connection is resolved like the CLI does it without flags: from the
+// env vars (MSSQL_DSN or MSSQL_HOST, MSSQL_PORT, MSSQL_USER, MSSQL_PASS,
+// MSSQL_PASS_FILE, MSSQL_AUTH, ...), the profile of the config files and
+// the defaults.
+//
+//	func TestPokemon(t *testing.T) {
+//		con := mssqltest.NewDB(t)
+//		mssqltest.LoadSQL(t, con, "testdata/init.sql")
+//		mssqltest.LoadCSV(t, con, "pokemon.pokemon", "testdata/pokemon.csv", mssqlload.CSVOptions{})
+//		mssqltest.AssertRowCount(t, con, "pokemon.pokemon", 3)
+//	}
+//
+// Fixtures loaded into a transaction of Tx are rolled back when the test
+// finishes:
+//
+//	tx := mssqltest.Tx(t, con)
+//	mssqltest.LoadSQL(t, tx, "testdata/pokemon.sql")
+package mssqltest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jwbargsten/go-mssql-load/config"
+	"github.com/jwbargsten/go-mssql-load/db"
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+	"github.com/jwbargsten/go-mssql-load/util"
+)
+
+// UpdateEnv is the env var that, if set to "1", makes AssertQueryJSON
+// rewrite the golden files instead of comparing against them.
+const UpdateEnv = "MSSQL_TEST_UPDATE"
+
+// Queryer is implemented by *sql.DB, *sql.Tx, *sqlx.DB, *sqlx.Tx and
+// *sql.Conn.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// DSN returns the DSN for the given database (the configured database if
+// empty), resolved like the CLI does it, see the package doc.
+func DSN(database string) (*url.URL, error) {
+	cfg, err := config.Load("", config.DefaultFiles()...)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ResolvePassword(); err != nil {
+		return nil, err
+	}
+	util.AddSecret(cfg.Pass)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	dsn, err := db.DSNFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if database != "" {
+		dsn = db.WithDatabase(dsn, database)
+	}
+	return dsn, nil
+}
+
+// Open connects to the given database (the configured database if empty).
+// The test fails if the server cannot be reached. The connection is closed
+// when the test finishes.
+func Open(t testing.TB, database string) *sqlx.DB {
+	t.Helper()
+	dsn, err := DSN(database)
+	if err != nil {
+		t.Fatalf("could not build DSN: %v", err)
+	}
+	con, err := db.Open(dsn)
+	if err != nil {
+		t.Fatalf("could not open db connection: %v", err)
+	}
+	t.Cleanup(func() { con.Close() })
+	if err := con.PingContext(context.Background()); err != nil {
+		t.Fatalf("could not connect to %s: %v", dsn.Host, err)
+	}
+	return con
+}
+
+var nonWord = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// databaseName derives a unique database name from the test name.
+func databaseName(testName string) string {
+	name := strings.Trim(nonWord.ReplaceAllString(testName, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return "test_" + name + "_" + hex.EncodeToString(suffix)
+}
+
+// NewDB creates a throwaway database for the test and connects to it. The
+// database is dropped when the test finishes.
+func NewDB(t testing.TB) *sqlx.DB {
+	t.Helper()
+	admin := Open(t, "master")
+	name := databaseName(t.Name())
+	ctx := context.Background()
+	if _, err := admin.ExecContext(ctx, "CREATE DATABASE "+mssqlload.QuoteName(name)); err != nil {
+		t.Fatalf("could not create database %s: %v", name, err)
+	}
+	con := Open(t, name)
+	// cleanups run in LIFO order, so the connection is closed before the drop
+	t.Cleanup(func() {
+		con.Close()
+		stmt := fmt.Sprintf("ALTER DATABASE %[1]s SET SINGLE_USER WITH ROLLBACK IMMEDIATE; DROP DATABASE %[1]s", mssqlload.QuoteName(name))
+		if _, err := admin.ExecContext(ctx, stmt); err != nil {
+			t.Errorf("could not drop database %s: %v", name, err)
+		}
+	})
+	return con
+}
+
+// Tx begins a transaction that is rolled back when the test finishes, so
+// that tests sharing a database do not see each other's changes. Fixtures
+// are loaded into it by passing it to LoadSQL and LoadCSV.
+func Tx(t testing.TB, con *sqlx.DB) *sqlx.Tx {
+	t.Helper()
+	tx, err := con.BeginTxx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("could not begin transaction: %v", err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+// Handle is a connection or a transaction of Tx.
+type Handle interface {
+	*sqlx.DB | *sqlx.Tx
+}
+
+// LoadSQL executes the SQL fixture files, see mssqlload.LoadSQL. If con is
+// a transaction, the fixtures are loaded into it.
+func LoadSQL[H Handle](t testing.TB, con H, paths ...string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch c := any(con).(type) {
+		case *sqlx.Tx:
+			_, err = mssqlload.LoadSQLTx(ctx, c.Tx, f, mssqlload.LoadSQLOptions{})
+		case *sqlx.DB:
+			_, err = mssqlload.LoadSQL(ctx, c.DB, f, mssqlload.LoadSQLOptions{})
+		}
+		f.Close()
+		if err != nil {
+			t.Fatalf("could not load %s: %v", p, err)
+		}
+	}
+}
+
+// LoadCSV bulk loads the CSV fixture file into table, see mssqlload.LoadCSV.
+// If con is a transaction, the rows are loaded into it.
+func LoadCSV[H Handle](t testing.TB, con H, table string, path string, opts mssqlload.CSVOptions) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ctx := context.Background()
+	switch c := any(con).(type) {
+	case *sqlx.Tx:
+		_, err = mssqlload.LoadCSVTx(ctx, c.Tx, table, f, opts)
+	case *sqlx.DB:
+		_, err = mssqlload.LoadCSV(ctx, c.DB, table, f, opts)
+	}
+	if err != nil {
+		t.Fatalf("could not load %s into %s: %v", path, table, err)
+	}
+}
+
+// AssertRowCount checks that table has n rows.
+func AssertRowCount(t testing.TB, q Queryer, table string, n int64) {
+	t.Helper()
+	var count int64
+	// table is not quoted, so that schema.table works
+	row := q.QueryRowContext(context.Background(), "SELECT COUNT_BIG(*) FROM "+table)
+	if err := row.Scan(&count); err != nil {
+		t.Fatalf("could not count rows of %s: %v", table, err)
+	}
+	if count != n {
+		t.Errorf("expected %d rows in %s, got %d", n, table, count)
+	}
+}
+
+// AssertQueryJSON runs query and compares the result, rendered as
+// newline delimited JSON (like querysql), with the golden file. If
+// MSSQL_TEST_UPDATE=1, the golden file is written instead.
+func AssertQueryJSON(t testing.TB, q Queryer, query string, golden string) {
+	t.Helper()
+	rows, err := q.QueryContext(context.Background(), query)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	var buf bytes.Buffer
+	if _, err := mssqlload.WriteRows(&buf, rows, mssqlload.FormatJSON); err != nil {
+		t.Fatalf("could not read result: %v", err)
+	}
+
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("could not read golden file (%s=1 creates it): %v", UpdateEnv, err)
+	}
+	if !bytes.Equal(expected, buf.Bytes()) {
+		t.Errorf("result of query differs from %s\nexpected:\n%s\ngot:\n%s", golden, expected, buf.Bytes())
+	}
+}
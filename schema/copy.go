@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+)
+
+// WriteCopy writes a T-SQL script (batches separated by GO) that creates the
+// schema s in an empty database and copies the rows of all tables from the
+// database src on the same server. The constraints are not checked while
+// copying, so that the order of the tables does not matter, and are
+// validated afterwards. Only the objects known to Schema are created, e.g.
+// no procedures, functions or triggers.
+func WriteCopy(w io.Writer, s *Schema, src string) error {
+	var stmts []string
+	schemas := map[string]bool{"dbo": true}
+	for _, name := range append(sortedKeys(s.Tables), sortedKeys(s.Views)...) {
+		schema, _ := mssqlload.SplitTableName(name)
+		if !schemas[schema] {
+			schemas[schema] = true
+			stmts = append(stmts, "CREATE SCHEMA "+mssqlload.QuoteName(schema))
+		}
+	}
+	for _, stmt := range stmts {
+		if _, err := fmt.Fprintf(w, "%s\nGO\n\n", stmt); err != nil {
+			return err
+		}
+	}
+	if err := WriteMigration(w, Diff(&Schema{}, s)); err != nil {
+		return err
+	}
+
+	stmts = nil
+	names := sortedKeys(s.Tables)
+	for _, name := range names {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s NOCHECK CONSTRAINT ALL", mssqlload.QuoteTableName(name)))
+	}
+	for _, name := range names {
+		stmts = append(stmts, copyRows(s.Tables[name], src))
+	}
+	for _, name := range names {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s WITH CHECK CHECK CONSTRAINT ALL", mssqlload.QuoteTableName(name)))
+	}
+	for _, stmt := range stmts {
+		if _, err := fmt.Fprintf(w, "%s\nGO\n\n", stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRows returns the statement that copies the rows of t from the
+// database src. Computed columns are created with their expression and
+// rowversion columns cannot be inserted, so both are left to the server.
+func copyRows(t *Table, src string) string {
+	var cols []string
+	identity := false
+	for _, c := range t.Columns {
+		if c.Computed || c.Type == "timestamp" || c.Type == "rowversion" {
+			continue
+		}
+		cols = append(cols, c.Name)
+		identity = identity || c.Identity
+	}
+	schema, table := mssqlload.SplitTableName(t.Name)
+	qtable := mssqlload.QuoteTableName(t.Name)
+	stmt := fmt.Sprintf("INSERT INTO %s (%s)\nSELECT %s FROM %s.%s.%s", qtable, quoteNames(cols), quoteNames(cols),
+		mssqlload.QuoteName(src), mssqlload.QuoteName(schema), mssqlload.QuoteName(table))
+	if !identity {
+		return stmt
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("SET IDENTITY_INSERT %s ON", qtable),
+		stmt,
+		fmt.Sprintf("SET IDENTITY_INSERT %s OFF", qtable),
+	}, ";\n")
+}
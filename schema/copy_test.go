@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteCopy(t *testing.T) {
+	_, to := testSchemas()
+	to.Tables["pokemon.pokemon"].Columns = append(to.Tables["pokemon.pokemon"].Columns,
+		Column{Name: "total", Type: "int", Computed: true, Expression: "([id]*(2))", Nullable: true})
+	// sorted by name, a_strong would come first
+	to.Views["pokemon.a_strong"] = View{
+		Name:       "pokemon.a_strong",
+		Definition: "CREATE VIEW pokemon.a_strong AS SELECT * FROM pokemon.strong",
+		DependsOn:  []string{"pokemon.strong"},
+	}
+	var buf bytes.Buffer
+	if err := WriteCopy(&buf, to, "pokedb"); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+	stmts := []string{
+		"CREATE SCHEMA [pokemon]\nGO",
+		"CREATE TABLE [pokemon].[pokemon] (\n" +
+			"  [id] int IDENTITY NOT NULL,\n" +
+			"  [name] varchar(255) NOT NULL,\n" +
+			"  [legendary] bit NOT NULL DEFAULT ((0)),\n" +
+			"  [total] AS ([id]*(2)),\n" +
+			"  PRIMARY KEY ([id])\n" +
+			")\nGO",
+		"CREATE VIEW pokemon.strong AS SELECT * FROM pokemon.pokemon\nGO",
+		"CREATE VIEW pokemon.a_strong AS SELECT * FROM pokemon.strong\nGO",
+		"ALTER TABLE [pokemon].[pokemon] NOCHECK CONSTRAINT ALL\nGO",
+		"SET IDENTITY_INSERT [pokemon].[pokemon] ON;\n" +
+			"INSERT INTO [pokemon].[pokemon] ([id], [name], [legendary])\n" +
+			"SELECT [id], [name], [legendary] FROM [pokedb].[pokemon].[pokemon];\n" +
+			"SET IDENTITY_INSERT [pokemon].[pokemon] OFF\nGO",
+		"ALTER TABLE [pokemon].[pokemon] WITH CHECK CHECK CONSTRAINT ALL\nGO",
+	}
+	last := -1
+	for _, stmt := range stmts {
+		i := strings.Index(script, stmt)
+		if i < 0 {
+			t.Fatalf("expected %q in script:\n%s", stmt, script)
+		}
+		if i < last {
+			t.Errorf("expected %q later in script:\n%s", stmt, script)
+		}
+		last = i
+	}
+}
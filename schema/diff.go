@@ -0,0 +1,245 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Change kinds.
+const (
+	Added   = "+"
+	Removed = "-"
+	Changed = "~"
+)
+
+// Change describes the difference of one object. From and To are the
+// objects in the old and the new schema, nil if the object was added or
+// removed respectively.
+type Change struct {
+	Kind string
+	// Object is table, view, column, index, foreign key or check.
+	Object string
+	// Table is the table of a column, index or constraint.
+	Table string
+	Name  string
+	From  any
+	To    any
+}
+
+// Diff returns the changes that turn the schema from into the schema to.
+// Tables and views are reported before their columns and constraints, views
+// after the views they depend on.
+func Diff(from, to *Schema) []Change {
+	var changes []Change
+	for _, name := range sortedKeys(from.Tables) {
+		if _, ok := to.Tables[name]; !ok {
+			changes = append(changes, Change{Kind: Removed, Object: "table", Name: name, From: from.Tables[name]})
+		}
+	}
+	for _, name := range sortedKeys(to.Tables) {
+		t := to.Tables[name]
+		old, ok := from.Tables[name]
+		if !ok {
+			changes = append(changes, Change{Kind: Added, Object: "table", Name: name, To: t})
+			continue
+		}
+		changes = append(changes, diffTable(old, t)...)
+	}
+	for _, name := range sortedKeys(from.Views) {
+		if _, ok := to.Views[name]; !ok {
+			changes = append(changes, Change{Kind: Removed, Object: "view", Name: name, From: from.Views[name]})
+		}
+	}
+	for _, name := range viewOrder(to.Views) {
+		v := to.Views[name]
+		old, ok := from.Views[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: Added, Object: "view", Name: name, To: v})
+		case normalizeDefinition(old.Definition) != normalizeDefinition(v.Definition):
+			changes = append(changes, Change{Kind: Changed, Object: "view", Name: name, From: old, To: v})
+		}
+	}
+	return changes
+}
+
+func diffTable(from, to *Table) []Change {
+	var changes []Change
+	add := func(kind, object, name string, f, t any) {
+		changes = append(changes, Change{Kind: kind, Object: object, Table: to.Name, Name: name, From: f, To: t})
+	}
+
+	fromCols := map[string]Column{}
+	for _, c := range from.Columns {
+		fromCols[c.Name] = c
+	}
+	toCols := map[string]bool{}
+	for _, c := range to.Columns {
+		toCols[c.Name] = true
+		old, ok := fromCols[c.Name]
+		switch {
+		case !ok:
+			add(Added, "column", c.Name, nil, c)
+		case old.Type != c.Type || old.Nullable != c.Nullable || old.Identity != c.Identity ||
+			normalizeDefinition(old.Expression) != normalizeDefinition(c.Expression) || old.Persisted != c.Persisted ||
+			normalizeDefinition(old.Default) != normalizeDefinition(c.Default):
+			add(Changed, "column", c.Name, old, c)
+		}
+	}
+	for _, c := range from.Columns {
+		if !toCols[c.Name] {
+			add(Removed, "column", c.Name, c, nil)
+		}
+	}
+
+	diffKeyed(from.Indexes, to.Indexes, indexKey, func(kind string, f, t *Index) {
+		add(kind, "index", indexName(f, t), ptrValue(f), ptrValue(t))
+	})
+	diffKeyed(from.ForeignKeys, to.ForeignKeys, foreignKeyKey, func(kind string, f, t *ForeignKey) {
+		add(kind, "foreign key", foreignKeyKey(*firstNonNil(f, t)), ptrValue(f), ptrValue(t))
+	})
+	diffKeyed(from.Checks, to.Checks, checkKey, func(kind string, f, t *Check) {
+		add(kind, "check", checkKey(*firstNonNil(f, t)), ptrValue(f), ptrValue(t))
+	})
+	return changes
+}
+
+// diffKeyed matches the objects by key and reports added, removed and
+// changed (as determined by the string representation) objects.
+func diffKeyed[T any](from, to []T, key func(T) string, report func(kind string, f, t *T)) {
+	fromByKey := map[string]*T{}
+	for i := range from {
+		fromByKey[key(from[i])] = &from[i]
+	}
+	seen := map[string]bool{}
+	for i := range to {
+		k := key(to[i])
+		seen[k] = true
+		old, ok := fromByKey[k]
+		switch {
+		case !ok:
+			report(Added, nil, &to[i])
+		case describe(*old) != describe(to[i]):
+			report(Changed, old, &to[i])
+		}
+	}
+	for i := range from {
+		if !seen[key(from[i])] {
+			report(Removed, &from[i], nil)
+		}
+	}
+}
+
+func ptrValue[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func firstNonNil[T any](a, b *T) *T {
+	if a != nil {
+		return a
+	}
+	return b
+}
+
+// primary keys and unique constraints are usually system named, so they are
+// matched by their role and columns
+func indexKey(idx Index) string {
+	switch {
+	case idx.Primary:
+		return "PRIMARY KEY"
+	case idx.SystemNamed:
+		return "UNIQUE (" + strings.Join(idx.Columns, ", ") + ")"
+	}
+	return idx.Name
+}
+
+func indexName(f, t *Index) string {
+	return indexKey(*firstNonNil(t, f))
+}
+
+func foreignKeyKey(fk ForeignKey) string {
+	if fk.SystemNamed {
+		return fmt.Sprintf("(%s) -> %s(%s)", strings.Join(fk.Columns, ", "), fk.RefTable, strings.Join(fk.RefColumns, ", "))
+	}
+	return fk.Name
+}
+
+func checkKey(c Check) string {
+	if c.SystemNamed {
+		return normalizeDefinition(c.Definition)
+	}
+	return c.Name
+}
+
+// describe renders an object for the human-readable diff.
+func describe(v any) string {
+	switch x := v.(type) {
+	case Column:
+		s := x.Type
+		if x.Computed {
+			s = "AS " + normalizeDefinition(x.Expression)
+			if x.Persisted {
+				s += " PERSISTED"
+			}
+		}
+		if x.Nullable {
+			s += " NULL"
+		} else {
+			s += " NOT NULL"
+		}
+		if x.Identity {
+			s += " IDENTITY"
+		}
+		if x.Default != "" {
+			s += " DEFAULT " + normalizeDefinition(x.Default)
+		}
+		return s
+	case Index:
+		s := "(" + strings.Join(x.Columns, ", ") + ")"
+		if x.Unique && !x.Primary {
+			s = "UNIQUE " + s
+		}
+		return s
+	case ForeignKey:
+		return fmt.Sprintf("(%s) REFERENCES %s(%s)", strings.Join(x.Columns, ", "), x.RefTable, strings.Join(x.RefColumns, ", "))
+	case Check:
+		return normalizeDefinition(x.Definition)
+	}
+	return ""
+}
+
+// WriteDiff renders the changes in a human-readable form.
+func WriteDiff(w io.Writer, changes []Change) error {
+	table := ""
+	for _, c := range changes {
+		var line string
+		switch c.Object {
+		case "table", "view":
+			table = ""
+			line = fmt.Sprintf("%s %s %s", c.Kind, c.Object, c.Name)
+		default:
+			if c.Table != table {
+				table = c.Table
+				if _, err := fmt.Fprintf(w, "%s table %s\n", Changed, table); err != nil {
+					return err
+				}
+			}
+			switch c.Kind {
+			case Added:
+				line = fmt.Sprintf("    + %s %s %s", c.Object, c.Name, describe(c.To))
+			case Removed:
+				line = fmt.Sprintf("    - %s %s %s", c.Object, c.Name, describe(c.From))
+			default:
+				line = fmt.Sprintf("    ~ %s %s: %s -> %s", c.Object, c.Name, describe(c.From), describe(c.To))
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package schema
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testSchemas() (*Schema, *Schema) {
+	from := &Schema{
+		Tables: map[string]*Table{
+			"pokemon.pokemon": {
+				Name: "pokemon.pokemon",
+				Columns: []Column{
+					{Name: "id", Type: "int", Identity: true},
+					{Name: "name", Type: "varchar(100)"},
+					{Name: "hp", Type: "int", Nullable: true},
+				},
+				Indexes: []Index{{Name: "PK__pokemon__3213E83F1A", Columns: []string{"id"}, Unique: true, Primary: true, SystemNamed: true}},
+			},
+			"pokemon.old": {Name: "pokemon.old", Columns: []Column{{Name: "id", Type: "int"}}},
+		},
+		Views: map[string]View{},
+	}
+	to := &Schema{
+		Tables: map[string]*Table{
+			"pokemon.pokemon": {
+				Name: "pokemon.pokemon",
+				Columns: []Column{
+					{Name: "id", Type: "int", Identity: true},
+					{Name: "name", Type: "varchar(255)"},
+					{Name: "legendary", Type: "bit", Default: "((0))"},
+				},
+				Indexes: []Index{
+					{Name: "PK__pokemon__3213E83F2B", Columns: []string{"id"}, Unique: true, Primary: true, SystemNamed: true},
+					{Name: "ix_name", Columns: []string{"name"}, Unique: true},
+				},
+				ForeignKeys: []ForeignKey{{Name: "fk_type", Columns: []string{"type_id"}, RefTable: "pokemon.types", RefColumns: []string{"id"}}},
+			},
+		},
+		Views: map[string]View{
+			"pokemon.strong": {Name: "pokemon.strong", Definition: "CREATE VIEW pokemon.strong AS SELECT * FROM pokemon.pokemon"},
+		},
+	}
+	return from, to
+}
+
+func TestDiff(t *testing.T) {
+	from, to := testSchemas()
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, Diff(from, to)); err != nil {
+		t.Fatal(err)
+	}
+	expected := `- table pokemon.old
+~ table pokemon.pokemon
+    ~ column name: varchar(100) NOT NULL -> varchar(255) NOT NULL
+    + column legendary bit NOT NULL DEFAULT ((0))
+    - column hp int NULL
+    + index ix_name UNIQUE (name)
+    + foreign key fk_type (type_id) REFERENCES pokemon.types(id)
++ view pokemon.strong
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	if changes := Diff(to, to); len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestWriteMigration(t *testing.T) {
+	from, to := testSchemas()
+	var buf bytes.Buffer
+	if err := WriteMigration(&buf, Diff(from, to)); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+	for _, stmt := range []string{
+		"DROP TABLE [pokemon].[old]\nGO",
+		"ALTER TABLE [pokemon].[pokemon] ALTER COLUMN [name] varchar(255) NOT NULL\nGO",
+		"ALTER TABLE [pokemon].[pokemon] ADD [legendary] bit NOT NULL DEFAULT ((0))\nGO",
+		"ALTER TABLE [pokemon].[pokemon] DROP COLUMN [hp]\nGO",
+		"CREATE UNIQUE INDEX [ix_name] ON [pokemon].[pokemon] ([name])\nGO",
+		"ALTER TABLE [pokemon].[pokemon] ADD CONSTRAINT [fk_type] FOREIGN KEY ([type_id]) REFERENCES [pokemon].[types] ([id])\nGO",
+		"CREATE VIEW pokemon.strong AS SELECT * FROM pokemon.pokemon\nGO",
+	} {
+		if !strings.Contains(script, stmt) {
+			t.Errorf("expected %q in script:\n%s", stmt, script)
+		}
+	}
+	if strings.Index(script, "DROP TABLE") > strings.Index(script, "FOREIGN KEY") {
+		t.Errorf("expected foreign keys to be created last:\n%s", script)
+	}
+}
+
+func TestWriteMigrationDependencies(t *testing.T) {
+	from := &Schema{Tables: map[string]*Table{
+		"dbo.types": {Name: "dbo.types", Columns: []Column{{Name: "id", Type: "int"}}},
+		"dbo.pokemon": {
+			Name: "dbo.pokemon",
+			Columns: []Column{
+				{Name: "id", Type: "int"},
+				{Name: "hp", Type: "int", Default: "((0))", DefaultName: "DF__pokemon__hp__1A"},
+			},
+		},
+	}}
+	to := &Schema{Tables: map[string]*Table{
+		"dbo.pokemon": {
+			Name: "dbo.pokemon",
+			Columns: []Column{
+				{Name: "id", Type: "int"},
+				{Name: "total", Type: "int", Computed: true, Expression: "([id]*(2))", Persisted: true, Nullable: true},
+			},
+		},
+	}}
+	var buf bytes.Buffer
+	if err := WriteMigration(&buf, Diff(from, to)); err != nil {
+		t.Fatal(err)
+	}
+	script := buf.String()
+	stmts := []string{
+		"FROM sys.foreign_keys WHERE referenced_object_id = OBJECT_ID(N'[dbo].[types]');\nEXEC sp_executesql @sql\nGO",
+		"DROP TABLE [dbo].[types]\nGO",
+		"ALTER TABLE [dbo].[pokemon] ADD [total] AS ([id]*(2)) PERSISTED\nGO",
+		"ALTER TABLE [dbo].[pokemon] DROP CONSTRAINT [DF__pokemon__hp__1A]\nGO",
+		"ALTER TABLE [dbo].[pokemon] DROP COLUMN [hp]\nGO",
+	}
+	last := -1
+	for _, stmt := range stmts {
+		i := strings.Index(script, stmt)
+		if i < 0 {
+			t.Fatalf("expected %q in script:\n%s", stmt, script)
+		}
+		if i < last {
+			t.Errorf("expected %q later in script:\n%s", stmt, script)
+		}
+		last = i
+	}
+}
+
+func TestViewOrder(t *testing.T) {
+	views := map[string]View{
+		"dbo.a": {Name: "dbo.a", DependsOn: []string{"dbo.c"}},
+		"dbo.b": {Name: "dbo.b"},
+		"dbo.c": {Name: "dbo.c", DependsOn: []string{"dbo.b", "other.gone"}},
+	}
+	if got, want := viewOrder(views), []string{"dbo.b", "dbo.c", "dbo.a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	cases := map[string]string{
+		TypeName("nvarchar", 510, 0, 0): "nvarchar(255)",
+		TypeName("varchar", -1, 0, 0):   "varchar(max)",
+		TypeName("decimal", 9, 10, 2):   "decimal(10,2)",
+		TypeName("datetime2", 8, 27, 7): "datetime2(7)",
+		TypeName("int", 4, 10, 0):       "int",
+	}
+	for got, expected := range cases {
+		if got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package schema reads the structure of a database (tables, columns, indexes,
+// constraints and views) from the sys.* catalog views and compares two of
+// them.
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+)
+
+type Schema struct {
+	// Tables and Views are keyed by "schema.name".
+	Tables map[string]*Table
+	Views  map[string]View
+}
+
+type Table struct {
+	Name        string
+	Columns     []Column
+	Indexes     []Index
+	ForeignKeys []ForeignKey
+	Checks      []Check
+}
+
+type Column struct {
+	Name string
+	// Type includes length, precision and scale, e.g. varchar(255).
+	Type     string
+	Nullable bool
+	Identity bool
+	Computed bool
+	// Expression is the definition of a computed column, e.g. ([a]+[b]).
+	Expression string
+	Persisted  bool
+	// Default is the definition of the default constraint, e.g. ((0)).
+	Default string
+	// DefaultName is the name of the default constraint. It is usually
+	// generated and not compared.
+	DefaultName string
+}
+
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+	// SystemNamed is set for primary key and unique constraints with a
+	// generated name.
+	SystemNamed bool
+}
+
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	// SystemNamed constraints are compared by their definition, not by name.
+	SystemNamed bool
+}
+
+type Check struct {
+	Name        string
+	Definition  string
+	SystemNamed bool
+}
+
+type View struct {
+	Name       string
+	Definition string
+	// DependsOn are the views the view selects from, as "schema.name".
+	DependsOn []string
+}
+
+const columnsQuery = `SELECT s.name, t.name, c.name, ty.name, c.max_length, c.precision, c.scale,
+  c.is_nullable, c.is_identity, c.is_computed, COALESCE(cc.definition, ''), COALESCE(cc.is_persisted, 0),
+  COALESCE(dc.definition, ''), COALESCE(dc.name, '')
+FROM sys.tables t
+JOIN sys.schemas s ON s.schema_id = t.schema_id
+JOIN sys.columns c ON c.object_id = t.object_id
+JOIN sys.types ty ON ty.user_type_id = c.user_type_id
+LEFT JOIN sys.computed_columns cc ON cc.object_id = c.object_id AND cc.column_id = c.column_id
+LEFT JOIN sys.default_constraints dc ON dc.object_id = c.default_object_id
+WHERE t.is_ms_shipped = 0 AND (@p1 = '' OR (s.name = @p2 AND t.name = @p3))
+ORDER BY s.name, t.name, c.column_id`
+
+const indexesQuery = `SELECT s.name, t.name, i.name, i.is_unique, i.is_primary_key,
+  COALESCE(kc.is_system_named, 0), c.name
+FROM sys.indexes i
+JOIN sys.tables t ON t.object_id = i.object_id
+JOIN sys.schemas s ON s.schema_id = t.schema_id
+LEFT JOIN sys.key_constraints kc ON kc.parent_object_id = i.object_id AND kc.unique_index_id = i.index_id
+JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE t.is_ms_shipped = 0 AND i.type > 0 AND ic.is_included_column = 0 AND (@p1 = '' OR (s.name = @p2 AND t.name = @p3))
+ORDER BY s.name, t.name, i.name, ic.key_ordinal`
+
+const foreignKeysQuery = `SELECT s.name, t.name, fk.name, fk.is_system_named, pc.name, rs.name, rt.name, rc.name
+FROM sys.foreign_keys fk
+JOIN sys.tables t ON t.object_id = fk.parent_object_id
+JOIN sys.schemas s ON s.schema_id = t.schema_id
+JOIN sys.tables rt ON rt.object_id = fk.referenced_object_id
+JOIN sys.schemas rs ON rs.schema_id = rt.schema_id
+JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id
+WHERE t.is_ms_shipped = 0 AND (@p1 = '' OR (s.name = @p2 AND t.name = @p3))
+ORDER BY s.name, t.name, fk.name, fkc.constraint_column_id`
+
+const checksQuery = `SELECT s.name, t.name, cc.name, cc.is_system_named, cc.definition
+FROM sys.check_constraints cc
+JOIN sys.tables t ON t.object_id = cc.parent_object_id
+JOIN sys.schemas s ON s.schema_id = t.schema_id
+WHERE t.is_ms_shipped = 0 AND (@p1 = '' OR (s.name = @p2 AND t.name = @p3))
+ORDER BY s.name, t.name, cc.name`
+
+const viewsQuery = `SELECT s.name, v.name, COALESCE(m.definition, '')
+FROM sys.views v
+JOIN sys.schemas s ON s.schema_id = v.schema_id
+LEFT JOIN sys.sql_modules m ON m.object_id = v.object_id
+WHERE v.is_ms_shipped = 0
+ORDER BY s.name, v.name`
+
+const viewDependenciesQuery = `SELECT DISTINCT s.name, v.name, rs.name, rv.name
+FROM sys.sql_expression_dependencies d
+JOIN sys.views v ON v.object_id = d.referencing_id
+JOIN sys.schemas s ON s.schema_id = v.schema_id
+JOIN sys.views rv ON rv.object_id = d.referenced_id
+JOIN sys.schemas rs ON rs.schema_id = rv.schema_id
+WHERE v.is_ms_shipped = 0 AND d.referenced_id <> d.referencing_id
+ORDER BY s.name, v.name, rs.name, rv.name`
+
+// TypeName formats a column type as in a CREATE TABLE statement. maxLength
+// is in bytes, as in sys.columns.
+func TypeName(name string, maxLength int, precision int, scale int) string {
+	switch name {
+	case "varchar", "char", "varbinary", "binary":
+		if maxLength < 0 {
+			return name + "(max)"
+		}
+		return fmt.Sprintf("%s(%d)", name, maxLength)
+	case "nvarchar", "nchar":
+		if maxLength < 0 {
+			return name + "(max)"
+		}
+		return fmt.Sprintf("%s(%d)", name, maxLength/2)
+	case "decimal", "numeric":
+		return fmt.Sprintf("%s(%d,%d)", name, precision, scale)
+	case "datetime2", "time", "datetimeoffset":
+		return fmt.Sprintf("%s(%d)", name, scale)
+	}
+	return name
+}
+
+// Introspect reads the schema of the database db is connected to.
+func Introspect(ctx context.Context, db *sql.DB) (*Schema, error) {
+	s := &Schema{Tables: map[string]*Table{}, Views: map[string]View{}}
+	if err := introspectTables(ctx, db, s, ""); err != nil {
+		return nil, err
+	}
+	err := each(ctx, db, viewsQuery, nil, func(rows *sql.Rows) error {
+		var schema, name string
+		var v View
+		if err := rows.Scan(&schema, &name, &v.Definition); err != nil {
+			return err
+		}
+		v.Name = schema + "." + name
+		s.Views[v.Name] = v
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read views: %w", err)
+	}
+	err = each(ctx, db, viewDependenciesQuery, nil, func(rows *sql.Rows) error {
+		var schema, name, refSchema, refName string
+		if err := rows.Scan(&schema, &name, &refSchema, &refName); err != nil {
+			return err
+		}
+		v := s.Views[schema+"."+name]
+		v.DependsOn = append(v.DependsOn, refSchema+"."+refName)
+		s.Views[v.Name] = v
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read view dependencies: %w", err)
+	}
+	return s, nil
+}
+
+// IntrospectTable reads a single table, given as "schema.table".
+func IntrospectTable(ctx context.Context, db *sql.DB, name string) (*Table, error) {
+	s := &Schema{Tables: map[string]*Table{}}
+	if err := introspectTables(ctx, db, s, name); err != nil {
+		return nil, err
+	}
+	schema, table := mssqlload.SplitTableName(name)
+	t, ok := s.Tables[schema+"."+table]
+	if !ok {
+		return nil, fmt.Errorf("table %s.%s not found", schema, table)
+	}
+	return t, nil
+}
+
+// introspectTables reads all tables, or only the table filter if not empty.
+func introspectTables(ctx context.Context, db *sql.DB, s *Schema, filter string) error {
+	args := []any{filter, "", ""}
+	if filter != "" {
+		args[1], args[2] = mssqlload.SplitTableName(filter)
+	}
+	table := func(schema, name string) *Table {
+		key := schema + "." + name
+		t, ok := s.Tables[key]
+		if !ok {
+			t = &Table{Name: key}
+			s.Tables[key] = t
+		}
+		return t
+	}
+
+	err := each(ctx, db, columnsQuery, args, func(rows *sql.Rows) error {
+		var schema, tname, typ string
+		var c Column
+		var maxLength, precision, scale int
+		if err := rows.Scan(&schema, &tname, &c.Name, &typ, &maxLength, &precision, &scale, &c.Nullable, &c.Identity, &c.Computed, &c.Expression, &c.Persisted, &c.Default, &c.DefaultName); err != nil {
+			return err
+		}
+		c.Type = TypeName(typ, maxLength, precision, scale)
+		t := table(schema, tname)
+		t.Columns = append(t.Columns, c)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not read columns: %w", err)
+	}
+
+	err = each(ctx, db, indexesQuery, args, func(rows *sql.Rows) error {
+		var schema, tname, col string
+		var idx Index
+		if err := rows.Scan(&schema, &tname, &idx.Name, &idx.Unique, &idx.Primary, &idx.SystemNamed, &col); err != nil {
+			return err
+		}
+		t := table(schema, tname)
+		if n := len(t.Indexes); n > 0 && t.Indexes[n-1].Name == idx.Name {
+			t.Indexes[n-1].Columns = append(t.Indexes[n-1].Columns, col)
+			return nil
+		}
+		idx.Columns = []string{col}
+		t.Indexes = append(t.Indexes, idx)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not read indexes: %w", err)
+	}
+
+	err = each(ctx, db, foreignKeysQuery, args, func(rows *sql.Rows) error {
+		var schema, tname, col, refSchema, refTable, refCol string
+		var fk ForeignKey
+		if err := rows.Scan(&schema, &tname, &fk.Name, &fk.SystemNamed, &col, &refSchema, &refTable, &refCol); err != nil {
+			return err
+		}
+		t := table(schema, tname)
+		if n := len(t.ForeignKeys); n > 0 && t.ForeignKeys[n-1].Name == fk.Name {
+			t.ForeignKeys[n-1].Columns = append(t.ForeignKeys[n-1].Columns, col)
+			t.ForeignKeys[n-1].RefColumns = append(t.ForeignKeys[n-1].RefColumns, refCol)
+			return nil
+		}
+		fk.Columns, fk.RefTable, fk.RefColumns = []string{col}, refSchema+"."+refTable, []string{refCol}
+		t.ForeignKeys = append(t.ForeignKeys, fk)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not read foreign keys: %w", err)
+	}
+
+	err = each(ctx, db, checksQuery, args, func(rows *sql.Rows) error {
+		var schema, tname string
+		var c Check
+		if err := rows.Scan(&schema, &tname, &c.Name, &c.SystemNamed, &c.Definition); err != nil {
+			return err
+		}
+		t := table(schema, tname)
+		t.Checks = append(t.Checks, c)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not read check constraints: %w", err)
+	}
+	return nil
+}
+
+func each(ctx context.Context, db *sql.DB, query string, args []any, fn func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// viewOrder returns the names of the views such that every view comes after
+// the views it depends on, otherwise sorted by name.
+func viewOrder(views map[string]View) []string {
+	var res []string
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		v, ok := views[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dep := range v.DependsOn {
+			visit(dep)
+		}
+		res = append(res, name)
+	}
+	for _, name := range sortedKeys(views) {
+		visit(name)
+	}
+	return res
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// normalizeDefinition makes definitions of views and constraints comparable
+// across servers, which may differ in whitespace and line endings.
+func normalizeDefinition(def string) string {
+	return strings.Join(strings.Fields(def), " ")
+}
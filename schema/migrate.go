@@ -0,0 +1,239 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jwbargsten/go-mssql-load/mssqlload"
+)
+
+// WriteMigration writes a T-SQL script (batches separated by GO) that applies
+// the changes. Foreign keys are dropped first and created last, so that the
+// order of the tables does not matter. Foreign keys of other tables that
+// reference a dropped table are looked up when the script runs. Changes that
+// cannot be expressed safely (e.g. changed defaults) are written as comments
+// to review.
+func WriteMigration(w io.Writer, changes []Change) error {
+	var drops, refDrops, tables, alters, creates, views []string
+
+	for _, c := range changes {
+		qtable := mssqlload.QuoteTableName(c.Table)
+		switch c.Object {
+		case "table":
+			switch c.Kind {
+			case Added:
+				t := c.To.(*Table)
+				tables = append(tables, createTable(t))
+				for _, idx := range t.Indexes {
+					if !idx.Primary {
+						creates = append(creates, createIndex(t.Name, idx))
+					}
+				}
+				for _, fk := range t.ForeignKeys {
+					creates = append(creates, addForeignKey(t.Name, fk))
+				}
+				for _, ck := range t.Checks {
+					creates = append(creates, addCheck(t.Name, ck))
+				}
+			case Removed:
+				t := c.From.(*Table)
+				for _, fk := range t.ForeignKeys {
+					drops = append(drops, dropConstraint(t.Name, fk.Name))
+				}
+				refDrops = append(refDrops, dropReferencingForeignKeys(t.Name))
+				tables = append(tables, "DROP TABLE "+mssqlload.QuoteTableName(c.Name))
+			}
+		case "view":
+			if c.Kind != Added {
+				drops = append(drops, "DROP VIEW "+mssqlload.QuoteTableName(c.Name))
+			}
+			if c.Kind != Removed {
+				views = append(views, strings.TrimSpace(c.To.(View).Definition))
+			}
+		case "column":
+			switch c.Kind {
+			case Added:
+				alters = append(alters, fmt.Sprintf("ALTER TABLE %s ADD %s", qtable, columnDefinition(c.To.(Column))))
+			case Removed:
+				col := c.From.(Column)
+				switch {
+				case col.DefaultName != "":
+					alters = append(alters, dropConstraint(c.Table, col.DefaultName))
+				case col.Default != "":
+					alters = append(alters, fmt.Sprintf("-- review: the default constraint of %s.%s has to be dropped first", qtable, mssqlload.QuoteName(c.Name)))
+				}
+				alters = append(alters, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", qtable, mssqlload.QuoteName(c.Name)))
+			case Changed:
+				alters = append(alters, alterColumn(qtable, c.From.(Column), c.To.(Column))...)
+			}
+		case "index":
+			if c.Kind != Added {
+				idx := c.From.(Index)
+				if idx.Primary || idx.SystemNamed {
+					drops = append(drops, dropConstraint(c.Table, idx.Name))
+				} else {
+					drops = append(drops, fmt.Sprintf("DROP INDEX %s ON %s", mssqlload.QuoteName(idx.Name), qtable))
+				}
+			}
+			if c.Kind != Removed {
+				creates = append(creates, createIndex(c.Table, c.To.(Index)))
+			}
+		case "foreign key":
+			if c.Kind != Added {
+				drops = append(drops, dropConstraint(c.Table, c.From.(ForeignKey).Name))
+			}
+			if c.Kind != Removed {
+				creates = append(creates, addForeignKey(c.Table, c.To.(ForeignKey)))
+			}
+		case "check":
+			if c.Kind != Added {
+				drops = append(drops, dropConstraint(c.Table, c.From.(Check).Name))
+			}
+			if c.Kind != Removed {
+				creates = append(creates, addCheck(c.Table, c.To.(Check)))
+			}
+		}
+	}
+
+	// the referencing foreign keys are dropped after the known ones, which
+	// would otherwise not exist anymore
+	for _, stmt := range concat(drops, refDrops, tables, alters, creates, views) {
+		if _, err := fmt.Fprintf(w, "%s\nGO\n\n", stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func concat(parts ...[]string) []string {
+	var res []string
+	for _, p := range parts {
+		res = append(res, p...)
+	}
+	return res
+}
+
+func columnDefinition(c Column) string {
+	if c.Computed {
+		s := mssqlload.QuoteName(c.Name) + " AS " + c.Expression
+		if c.Persisted {
+			s += " PERSISTED"
+			if !c.Nullable {
+				s += " NOT NULL"
+			}
+		}
+		return s
+	}
+	s := mssqlload.QuoteName(c.Name) + " " + c.Type
+	if c.Identity {
+		s += " IDENTITY"
+	}
+	if c.Nullable {
+		s += " NULL"
+	} else {
+		s += " NOT NULL"
+	}
+	if c.Default != "" {
+		s += " DEFAULT " + c.Default
+	}
+	return s
+}
+
+func createTable(t *Table) string {
+	var lines []string
+	for _, c := range t.Columns {
+		lines = append(lines, "  "+columnDefinition(c))
+	}
+	for _, idx := range t.Indexes {
+		if idx.Primary {
+			lines = append(lines, fmt.Sprintf("  %sPRIMARY KEY (%s)", constraintName(idx.Name, idx.SystemNamed), quoteNames(idx.Columns)))
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", mssqlload.QuoteTableName(t.Name), strings.Join(lines, ",\n"))
+}
+
+func alterColumn(qtable string, from, to Column) []string {
+	var stmts []string
+	if from.Identity != to.Identity {
+		stmts = append(stmts, fmt.Sprintf("-- review: identity of %s.%s changed, the column has to be recreated", qtable, mssqlload.QuoteName(to.Name)))
+	}
+	if from.Computed || to.Computed {
+		if normalizeDefinition(from.Expression) != normalizeDefinition(to.Expression) || from.Persisted != to.Persisted ||
+			from.Computed != to.Computed {
+			stmts = append(stmts, fmt.Sprintf("-- review: computed column %s.%s changed, the column has to be recreated", qtable, mssqlload.QuoteName(to.Name)))
+		}
+		return stmts
+	}
+	if from.Type != to.Type || from.Nullable != to.Nullable {
+		null := "NOT NULL"
+		if to.Nullable {
+			null = "NULL"
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s %s", qtable, mssqlload.QuoteName(to.Name), to.Type, null))
+	}
+	if normalizeDefinition(from.Default) != normalizeDefinition(to.Default) {
+		stmts = append(stmts, fmt.Sprintf("-- review: default of %s.%s changed from %q to %q, the default constraint has to be replaced",
+			qtable, mssqlload.QuoteName(to.Name), from.Default, to.Default))
+	}
+	return stmts
+}
+
+func createIndex(table string, idx Index) string {
+	if idx.Primary {
+		return fmt.Sprintf("ALTER TABLE %s ADD %sPRIMARY KEY (%s)",
+			mssqlload.QuoteTableName(table), constraintName(idx.Name, idx.SystemNamed), quoteNames(idx.Columns))
+	}
+	if idx.SystemNamed {
+		return fmt.Sprintf("ALTER TABLE %s ADD UNIQUE (%s)", mssqlload.QuoteTableName(table), quoteNames(idx.Columns))
+	}
+	unique := ""
+	if idx.Unique {
+		unique = "UNIQUE "
+	}
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, mssqlload.QuoteName(idx.Name), mssqlload.QuoteTableName(table), quoteNames(idx.Columns))
+}
+
+func addForeignKey(table string, fk ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %sFOREIGN KEY (%s) REFERENCES %s (%s)",
+		mssqlload.QuoteTableName(table), constraintName(fk.Name, fk.SystemNamed), quoteNames(fk.Columns),
+		mssqlload.QuoteTableName(fk.RefTable), quoteNames(fk.RefColumns))
+}
+
+func addCheck(table string, c Check) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %sCHECK %s", mssqlload.QuoteTableName(table), constraintName(c.Name, c.SystemNamed), c.Definition)
+}
+
+// constraintName returns the CONSTRAINT clause, which is omitted for
+// generated names, so that the target server generates its own.
+func constraintName(name string, systemNamed bool) string {
+	if systemNamed {
+		return ""
+	}
+	return "CONSTRAINT " + mssqlload.QuoteName(name) + " "
+}
+
+func dropConstraint(table string, name string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", mssqlload.QuoteTableName(table), mssqlload.QuoteName(name))
+}
+
+// dropReferencingForeignKeys returns a batch that drops the foreign keys of
+// all tables that reference table.
+func dropReferencingForeignKeys(table string) string {
+	literal := "N'" + strings.ReplaceAll(mssqlload.QuoteTableName(table), "'", "''") + "'"
+	return strings.Join([]string{
+		"DECLARE @sql nvarchar(max) = N'';",
+		"SELECT @sql += N'ALTER TABLE ' + QUOTENAME(OBJECT_SCHEMA_NAME(parent_object_id)) + N'.' + QUOTENAME(OBJECT_NAME(parent_object_id))",
+		"  + N' DROP CONSTRAINT ' + QUOTENAME(name) + N';'",
+		"FROM sys.foreign_keys WHERE referenced_object_id = OBJECT_ID(" + literal + ");",
+		"EXEC sp_executesql @sql",
+	}, "\n")
+}
+
+func quoteNames(names []string) string {
+	q := make([]string, len(names))
+	for i, n := range names {
+		q[i] = mssqlload.QuoteName(n)
+	}
+	return strings.Join(q, ", ")
+}
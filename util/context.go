@@ -0,0 +1,16 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ContextError attributes err to the cancellation or deadline of ctx, if
+// ctx is done. The driver does not always wrap the context error.
+func ContextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
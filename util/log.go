@@ -0,0 +1,163 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogOptions configure the logger built by BuildLogger.
+type LogOptions struct {
+	// Level is debug, info (default), warn or error.
+	Level string
+	// Format is console (default), json or logfmt.
+	Format string
+	// File is the log file, stderr if empty. Log lines are appended.
+	File string
+	// Quiet only logs errors, it takes precedence over Level.
+	Quiet bool
+}
+
+// LogFormats lists the supported log formats.
+var LogFormats = []string{"console", "json", "logfmt"}
+
+// BuildLogger builds a logger that redacts secrets, see AddSecret.
+func BuildLogger(opts LogOptions) (*zap.SugaredLogger, error) {
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", opts.Level)
+		}
+	}
+	if opts.Quiet {
+		level.SetLevel(zap.ErrorLevel)
+	}
+
+	var enc zapcore.Encoder
+	switch opts.Format {
+	case "", "console":
+		encCfg := zap.NewDevelopmentEncoderConfig()
+		encCfg.EncodeTime = zapcore.RFC3339TimeEncoder
+		enc = zapcore.NewConsoleEncoder(encCfg)
+	case "json", "logfmt":
+		encCfg := zap.NewProductionEncoderConfig()
+		encCfg.EncodeTime = zapcore.RFC3339TimeEncoder
+		enc = zapcore.NewJSONEncoder(encCfg)
+		if opts.Format == "logfmt" {
+			enc = logfmtEncoder{enc}
+		}
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected %s", opts.Format, strings.Join(LogFormats, ", "))
+	}
+
+	output := "stderr"
+	if opts.File != "" {
+		output = opts.File
+	}
+	sink, _, err := zap.Open(output)
+	if err != nil {
+		return nil, fmt.Errorf("could not open log file: %w", err)
+	}
+	core := redactCore{zapcore.NewCore(enc, sink, level)}
+	return zap.New(core, zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(zapcore.AddSync(os.Stderr)))).Sugar(), nil
+}
+
+// logfmtEncoder renders the entries of the JSON encoder as logfmt, e.g.
+//
+//	level=info ts=2024-01-02T15:04:05Z msg="inserted 3 rows" rows=3
+type logfmtEncoder struct {
+	zapcore.Encoder
+}
+
+var bufferPool = buffer.NewPool()
+
+func (e logfmtEncoder) Clone() zapcore.Encoder {
+	return logfmtEncoder{e.Encoder.Clone()}
+}
+
+func (e logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	buf, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Free()
+	out := bufferPool.Get()
+	if err := jsonToLogfmt(out, buf.Bytes()); err != nil {
+		out.Free()
+		return nil, err
+	}
+	return out, nil
+}
+
+// jsonToLogfmt converts a flat JSON object to a logfmt line, keeping the
+// order of the keys. Nested values are written as quoted JSON.
+func jsonToLogfmt(w io.Writer, line []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	sep := ""
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		var s string
+		switch x := v.(type) {
+		case string:
+			s = x
+		case json.Number:
+			s = x.String()
+		case bool:
+			s = fmt.Sprint(x)
+		case nil:
+			s = "null"
+		default:
+			b, _ := json.Marshal(x)
+			s = string(b)
+		}
+		fmt.Fprintf(w, "%s%s=%s", sep, key, logfmtValue(s))
+		sep = " "
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// DriverLogger adapts a logger to the logger interface of go-mssqldb. The
+// messages are logged at debug level.
+type DriverLogger struct {
+	log *zap.SugaredLogger
+}
+
+// NewDriverLogger returns a DriverLogger that reports the caller in the
+// driver instead of the adapter.
+func NewDriverLogger(log *zap.SugaredLogger) DriverLogger {
+	return DriverLogger{log: log.Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+func (l DriverLogger) Printf(format string, v ...any) {
+	l.log.Debugf("mssql: "+format, v...)
+}
+
+func (l DriverLogger) Println(v ...any) {
+	l.log.Debug("mssql: " + strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
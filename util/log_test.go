@@ -0,0 +1,66 @@
+package util
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestJSONToLogfmt(t *testing.T) {
+	var b bytes.Buffer
+	err := jsonToLogfmt(&b, []byte(`{"level":"info","msg":"inserted 3 rows","rows":3,"ok":true,"err":null,"tags":["a"],"empty":""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `level=info msg="inserted 3 rows" rows=3 ok=true err=null tags="[\"a\"]" empty=""` + "\n"
+	if b.String() != want {
+		t.Fatalf("got  %s\nwant %s", b.String(), want)
+	}
+}
+
+func TestBuildLogger(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "log")
+	log, err := BuildLogger(LogOptions{Format: "logfmt", Level: "warn", File: f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AddSecret("s3cr3t")
+	log.Info("hidden")
+	log.Warnw("password s3cr3t", "rows", 1)
+	_ = log.Sync()
+	raw, err := os.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(raw)
+	if strings.Contains(got, "hidden") || !strings.Contains(got, `level=warn`) ||
+		!strings.Contains(got, `msg="password xxxxx" rows=1`) {
+		t.Fatalf("got %s", got)
+	}
+
+	if _, err := BuildLogger(LogOptions{Level: "loud"}); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+	if _, err := BuildLogger(LogOptions{Format: "xml"}); err == nil {
+		t.Fatal("expected error for invalid format")
+	}
+}
+
+func TestDriverLogger(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	l := NewDriverLogger(zap.New(core, zap.AddCaller()).Sugar())
+	l.Printf("got %d rows", 3)
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Message != "mssql: got 3 rows" {
+		t.Fatalf("got %v", entries)
+	}
+	// the caller of Printf, not the adapter
+	if file := entries[0].Caller.File; !strings.HasSuffix(file, "log_test.go") {
+		t.Errorf("got caller %s", file)
+	}
+}
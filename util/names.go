@@ -0,0 +1,43 @@
+package util
+
+import "strings"
+
+// QuoteName quotes an identifier, like QUOTENAME in T-SQL.
+func QuoteName(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// SplitName splits a multi-part name like "db.schema.table" at the dots
+// outside of brackets and double quotes. The quotes are removed from the
+// parts, e.g. "[a.b].[c]]d]" is split into "a.b" and "c]d".
+func SplitName(name string) []string {
+	var parts []string
+	var part strings.Builder
+	var quote rune
+	runes := []rune(name)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0 && c == quote:
+			if i+1 < len(runes) && runes[i+1] == quote {
+				// escaped quote
+				part.WriteRune(c)
+				i++
+			} else {
+				quote = 0
+			}
+		case quote != 0:
+			part.WriteRune(c)
+		case c == '[':
+			quote = ']'
+		case c == '"':
+			quote = '"'
+		case c == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(c)
+		}
+	}
+	return append(parts, part.String())
+}
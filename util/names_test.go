@@ -0,0 +1,21 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitName(t *testing.T) {
+	cases := map[string]string{
+		"pokedb.pokemon.pokemon": "pokedb|pokemon|pokemon",
+		"[a.b].[c]]d]":           "a.b|c]d",
+		`"a.b".c`:                "a.b|c",
+		"pokedb..pokemon":        "pokedb||pokemon",
+		"[pokemon].[pokémon]":    "pokemon|pokémon",
+	}
+	for name, expected := range cases {
+		if got := strings.Join(SplitName(name), "|"); got != expected {
+			t.Errorf("SplitName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var secrets struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// AddSecret registers a value (e.g. a password) that is replaced by
+// "xxxxx" in all log lines and in texts passed through Redact. The escaped
+// forms of the value, as they appear in DSNs, are registered as well.
+func AddSecret(v string) {
+	if v == "" {
+		return
+	}
+	userinfo := strings.TrimPrefix(url.UserPassword("", v).String(), ":")
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	for _, s := range []string{v, url.QueryEscape(v), url.PathEscape(v), userinfo} {
+		if !contains(secrets.values, s) {
+			secrets.values = append(secrets.values, s)
+		}
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces all registered secrets in s.
+func Redact(s string) string {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+	for _, v := range secrets.values {
+		s = strings.ReplaceAll(s, v, "xxxxx")
+	}
+	return s
+}
+
+// redactCore redacts secrets from log messages and string/error fields.
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = Redact(ent.Message)
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	res := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = Redact(f.String)
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok {
+				f = zap.String(f.Key, Redact(err.Error()))
+			}
+		case zapcore.StringerType:
+			f = zap.String(f.Key, Redact(f.Interface.(interface{ String() string }).String()))
+		case zapcore.ReflectType:
+			// zap.Any with structs, maps, slices, ...
+			if raw, err := json.Marshal(f.Interface); err == nil {
+				f = zap.Reflect(f.Key, json.RawMessage(Redact(string(raw))))
+			} else {
+				f = zap.String(f.Key, Redact(fmt.Sprintf("%+v", f.Interface)))
+			}
+		}
+		res[i] = f
+	}
+	return res
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRedactEscaped(t *testing.T) {
+	AddSecret("p@ss w/rd&1")
+	dsn := url.URL{Scheme: "sqlserver", User: url.UserPassword("sa", "p@ss w/rd&1"), Host: "localhost"}
+	q := url.Values{"pwd": {"p@ss w/rd&1"}}
+	for _, s := range []string{"p@ss w/rd&1", dsn.String(), q.Encode(), url.PathEscape("p@ss w/rd&1")} {
+		if got := Redact(s); strings.Contains(got, "ss") {
+			t.Errorf("secret not redacted in %q: %q", s, got)
+		}
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	AddSecret("s3cr3t")
+	var b bytes.Buffer
+	core := redactCore{zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&b), zap.DebugLevel)}
+	log := zap.New(core)
+	log.Info("connect",
+		zap.Any("cfg", map[string]string{"pass": "s3cr3t"}),
+		zap.Stringer("dsn", &url.URL{Scheme: "sqlserver", User: url.UserPassword("sa", "s3cr3t")}),
+	)
+	if got := b.String(); strings.Contains(got, "s3cr3t") || !strings.Contains(got, `"cfg":{"pass":"xxxxx"}`) {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -2,7 +2,6 @@ package util
 
 import (
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 	"os"
 )
 
@@ -15,15 +14,11 @@ func OpenFileorStdin(f string, log *zap.SugaredLogger) (*os.File, error) {
 
 }
 
+// NewLogger returns the default logger, see BuildLogger.
 func NewLogger() *zap.SugaredLogger {
-	config := zap.NewDevelopmentConfig()
-	config.OutputPaths = []string{"stderr"}
-	config.EncoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
-	config.DisableStacktrace = true
-	log, err := config.Build()
+	log, err := BuildLogger(LogOptions{})
 	if err != nil {
 		panic(err)
 	}
-	return log.Sugar()
+	return log
 }
-